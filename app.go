@@ -2,17 +2,37 @@ package di
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"reflect"
+	"sync"
 	"time"
 )
 
 const (
 	StartTimeout = 30 * time.Second
 	StopTimeout  = 30 * time.Second
+
+	readyInterval = 10 * time.Millisecond
+)
+
+// Default timeouts of new apps.
+var (
+	defaultStartTimeout = StartTimeout
+	defaultStopTimeout  = StopTimeout
 )
 
+// SetDefaultTimeouts sets the start and stop timeouts of new apps, for example,
+// to shorten them in test suites. SetDefaultTimeouts(StartTimeout, StopTimeout) resets them.
+// It is not safe for concurrent use, call it during a single-threaded setup, e.g. in TestMain.
+func SetDefaultTimeouts(start, stop time.Duration) {
+	defaultStartTimeout = start
+	defaultStopTimeout = stop
+}
+
 // Starter is a service which should be started on an application startup.
 type Starter interface {
 	Start() error
@@ -23,11 +43,53 @@ type Stopper interface {
 	Stop() error
 }
 
+// HealthChecker is a service which reports whether it is healthy and ready to serve.
+type HealthChecker interface {
+	Health() error
+}
+
+// Killer is a service which should be killed when its graceful stop times out.
+type Killer interface {
+	Kill()
+}
+
+// Releaser is a service which reports whether it has released its resources after a stop.
+type Releaser interface {
+	Released() bool
+}
+
 // Logger is an application logger.
 type Logger interface {
 	Println(v ...interface{})
 }
 
+// Clock measures the start and stop timeouts, tests can use a fake clock to trigger them instantly.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Lifecycle phases.
+const (
+	PhaseStart = "start"
+	PhaseStop  = "stop"
+)
+
+// LifecycleError is returned when a service fails to start or stop.
+type LifecycleError struct {
+	Service reflect.Type
+	Phase   string
+	Err     error
+}
+
+func (e *LifecycleError) Error() string {
+	return fmt.Sprintf("di: failed to %v service, type=%v: %v", e.Phase, e.Service, e.Err)
+}
+
+func (e *LifecycleError) Unwrap() error {
+	return e.Err
+}
+
 // App provides a start/stop lifecycle and a graceful shutdown.
 // Usually, users should call app.Run() which starts the services in toplogical order
 // from dependencies to dependants. Then blocks until a SIGINT/SIGKILL signal arrives,
@@ -35,8 +97,38 @@ type Logger interface {
 type App struct {
 	Context      *Context
 	Logger       Logger
-	StartTimeout time.Duration
-	StopTimeout  time.Duration
+	BaseContext  context.Context // Parent of the start and stop contexts, defaults to context.Background().
+	StartTimeout time.Duration   // Zero means no timeout.
+	StopTimeout  time.Duration   // Zero means no timeout, the app waits until all services stop.
+	Clock        Clock           // Measures the start and stop timeouts, defaults to the system clock.
+	WaitReady    bool            // Wait for health checkers to pass before Start returns.
+	Verbose      bool            // Log routine and per-service lifecycle messages, failures are always logged.
+	StopParallel bool            // Stop independent services concurrently.
+
+	// RequireStarters makes Start fail when no services implement the Starter interface.
+	RequireStarters bool
+
+	// Signals returns a channel which receives stop signals, defaults to os/signal notifications.
+	Signals func() <-chan os.Signal
+
+	// DisableSignals makes Run and RunContext ignore stop signals, for example,
+	// when the app is embedded into a process which handles signals itself.
+	DisableSignals bool
+
+	mu      sync.Mutex
+	started chan struct{}
+	stopped chan struct{}
+
+	startedProviders []*Provider        // Providers passed by the last Start, in start order.
+	stoppedProviders map[*Provider]bool // Providers stopped since the last Start, skipped by later stops.
+	shutdownSignal   os.Signal          // Signal which stopped the last run.
+	serviceTimeouts  map[reflect.Type]serviceTimeout
+}
+
+// serviceTimeout is a per-service start and stop timeout.
+type serviceTimeout struct {
+	start time.Duration
+	stop  time.Duration
 }
 
 // NewApp creates a new application from modules.
@@ -49,101 +141,364 @@ func NewApp(modules ...ModuleFunc) (*App, error) {
 	app := &App{
 		Context:      ctx,
 		Logger:       log.New(os.Stderr, "", log.LstdFlags),
-		StartTimeout: StartTimeout,
-		StopTimeout:  StopTimeout,
+		StartTimeout: defaultStartTimeout,
+		StopTimeout:  defaultStopTimeout,
+		Signals:      notifySignals,
+		Verbose:      true,
 	}
 	return app, nil
 }
 
 // Run starts the application, awaits a stop signal and then stops the application.
 func (app *App) Run() error {
+	return app.RunContext(context.Background())
+}
+
+// RunContext starts the application, awaits a stop signal or the context cancellation,
+// and then stops the application.
+func (app *App) RunContext(ctx context.Context) error {
 	if err := app.runStart(); err != nil {
-		app.runStop()
+		app.rollback()
 		return err
 	}
 
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt, os.Kill)
-	<-ch
+	var signals <-chan os.Signal
+	if !app.DisableSignals {
+		if app.Signals != nil {
+			signals = app.Signals()
+		} else {
+			signals = notifySignals()
+		}
+	}
+
+	app.mu.Lock()
+	app.shutdownSignal = nil
+	app.mu.Unlock()
 
+	select {
+	case sig := <-signals:
+		app.logVerbose("Received signal:", sig)
+		app.mu.Lock()
+		app.shutdownSignal = sig
+		app.mu.Unlock()
+	case <-ctx.Done():
+	}
 	return app.runStop()
 }
 
-func (app *App) runStart() error {
-	startCtx := context.Background()
-	if app.StartTimeout > 0 {
-		var cancel context.CancelFunc
-		startCtx, cancel = context.WithTimeout(startCtx, app.StartTimeout)
-		defer cancel()
+// ShutdownSignal returns the signal which stopped the last run, or nil when the run
+// was stopped otherwise, for example, by the context cancellation.
+func (app *App) ShutdownSignal() os.Signal {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.shutdownSignal
+}
+
+// RunTask starts the application, runs a task and then stops the application.
+// It returns the task error if any, otherwise the stop error.
+func (app *App) RunTask(task func(*Context) error) error {
+	if err := app.runStart(); err != nil {
+		app.rollback()
+		return err
+	}
+
+	taskErr := task(app.Context)
+	stopErr := app.runStop()
+	if taskErr != nil {
+		return taskErr
+	}
+	return stopErr
+}
+
+// Reload rebuilds the context from new modules, stops the services whose providers
+// were removed or changed, and starts the new ones. Services with the same providers
+// and unchanged dependencies keep running and are reused in the new context, the invokes
+// are called again when they are new or their dependencies have changed.
+func (app *App) Reload(modules ...ModuleFunc) error {
+	app.logVerbose("Reloading...")
+
+	ctx, err := newContext(app.Context.options, modules)
+	if err != nil {
+		return err
+	}
+
+	unchanged := ctx.unchangedProviders(app.Context)
+	reused := map[*Provider]bool{}
+	ctx.reused = map[*Provider]interface{}{}
+	for p, p0 := range unchanged {
+		reused[p0] = true
+		ctx.reused[p] = app.Context.instances[p0]
+	}
+
+	ctx.building = true
+	err = ctx.initInstances()
+	ctx.building = false
+	ctx.reused = nil
+	if err == nil {
+		err = ctx.initInvokes(func(p *Provider) bool { return ctx.unchangedInvoke(app.Context, p, unchanged) })
+	}
+	if err == nil {
+		err = ctx.checkWarnings()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Stop the removed and changed services.
+	removed := []*Provider{}
+	for _, p := range app.Context.InstanceProviders {
+		if !reused[p] {
+			removed = append(removed, p)
+		}
+	}
+
+	stopCtx, cancel := app.stopContext()
+	err = app.stop(stopCtx, app.Context, removed, nil)
+	cancel()
+	if err != nil {
+		return err
 	}
-	return app.Start(startCtx)
+
+	// Start the new services.
+	added := []*Provider{}
+	for _, p := range ctx.InstanceProviders {
+		if _, ok := unchanged[p]; !ok {
+			added = append(added, p)
+		}
+	}
+
+	app.Context = ctx
+	ctx.Seal()
+	startCtx, cancel := app.startContext()
+	defer cancel()
+	_, err = app.start(startCtx, ctx, added)
+	return err
+}
+
+func (app *App) runStart() error {
+	ctx, cancel := app.startContext()
+	defer cancel()
+	return app.Start(ctx)
 }
 
 func (app *App) runStop() error {
-	stopCtx := context.Background()
-	if app.StopTimeout > 0 {
-		var cancel context.CancelFunc
-		stopCtx, cancel = context.WithTimeout(stopCtx, app.StopTimeout)
-		defer cancel()
+	ctx, cancel := app.stopContext()
+	defer cancel()
+	return app.Stop(ctx)
+}
+
+// rollback stops only the services which have been started by a failed start, in reverse order.
+func (app *App) rollback() error {
+	ctx, cancel := app.stopContext()
+	defer cancel()
+	defer app.closeChannel(&app.stopped)
+	return app.stop(ctx, app.Context, app.startedProviders, app.Context.stopHooks)
+}
+
+// SetServiceTimeout sets the start and stop timeouts of a service type, which bound its Start and Stop
+// calls in addition to the app timeouts. Zero timeouts are not applied.
+func (app *App) SetServiceTimeout(typ reflect.Type, start, stop time.Duration) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if app.serviceTimeouts == nil {
+		app.serviceTimeouts = map[reflect.Type]serviceTimeout{}
+	}
+	app.serviceTimeouts[typ] = serviceTimeout{start: start, stop: stop}
+}
+
+// serviceTimeout returns the start and stop timeouts of a service type.
+func (app *App) serviceTimeout(typ reflect.Type) serviceTimeout {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.serviceTimeouts[typ]
+}
+
+// startContext returns a start context, an existing base context deadline takes precedence over StartTimeout.
+func (app *App) startContext() (context.Context, context.CancelFunc) {
+	return timeoutContext(app.baseContext(), app.Clock, app.StartTimeout)
+}
+
+// stopContext returns a stop context bounded by StopTimeout, it keeps the base context values,
+// but not its cancellation and deadline, so that the services are still stopped when the base context is done.
+func (app *App) stopContext() (context.Context, context.CancelFunc) {
+	return timeoutContext(context.WithoutCancel(app.baseContext()), app.Clock, app.StopTimeout)
+}
+
+func (app *App) baseContext() context.Context {
+	if app.BaseContext == nil {
+		return context.Background()
 	}
-	return app.Stop(stopCtx)
+	return app.BaseContext
 }
 
-// Start starts the services which implement the Starter interface.
+// Start seals the context and starts the services which implement the Starter interface.
+// It is bounded only by the context, StartTimeout is not applied. A done context returns its error
+// without starting any services.
 func (app *App) Start(ctx context.Context) error {
-	app.log("Starting...")
+	app.Context.Seal()
+	app.startedProviders = nil
+	app.resetStopped()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if app.RequireStarters && !hasStarters(app.Context) {
+		err := errors.New("di: no services implement Starter")
+		app.log("Failed to start:", err)
+		return err
+	}
 
-	// Find the services which implement the Starter interface.
-	services := []Starter{}
-	for _, instance := range app.Context.InstanceSlice {
-		service, ok := instance.(Starter)
-		if ok {
-			services = append(services, service)
-		}
+	started, err := app.start(ctx, app.Context, app.Context.InstanceProviders)
+	app.startedProviders = started
+	if err != nil {
+		return err
 	}
+	app.closeChannel(&app.started)
+	return nil
+}
+
+// start starts the services which implement the Starter interface, and returns the providers
+// which precede the first failed service, i.e. the started ones.
+func (app *App) start(ctx context.Context, c *Context, providers []*Provider) ([]*Provider, error) {
+	app.logVerbose("Starting...")
 
 	// Start the services.
 	var err error
-	for _, service := range services {
-		if err = withTimeout(ctx, service.Start); err != nil {
+	started := providers
+	for i, p := range providers {
+		service, ok := c.instances[p].(Starter)
+		if !ok {
+			continue
+		}
+
+		begin := app.now()
+		serviceCtx, cancel := withClockTimeout(ctx, app.Clock, app.serviceTimeout(p.Type).start)
+		err = withTimeout(serviceCtx, service.Start)
+		cancel()
+		app.logEvent(PhaseStart, service, app.now().Sub(begin), err)
+		if err != nil {
+			if err != ctx.Err() {
+				err = &LifecycleError{Service: p.Type, Phase: PhaseStart, Err: err}
+			}
+			started = providers[:i]
 			break
 		}
 	}
 
+	// Wait until the services are ready.
+	if err == nil && app.WaitReady {
+		err = app.waitReady(ctx)
+	}
+
 	switch {
 	case ctx.Err() == err && err == context.DeadlineExceeded:
 		app.log("Start timed out.")
-		return err
+		return started, err
 
 	case err != nil:
 		app.log("Failed to start:", err)
-		return err
+		return started, err
 	}
 
-	app.log("Started.")
-	return nil
+	app.logVerbose("Started.")
+	return started, nil
 }
 
-// Stop stops the services which implement the Stopper interface.
+// Stop stops the services which implement the Stopper interface in reverse order,
+// and then runs the module stop hooks in reverse registration order.
+// A done context returns its error without stopping any services.
 func (app *App) Stop(ctx context.Context) error {
-	app.log("Stopping...")
+	defer app.closeChannel(&app.stopped)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return app.stop(ctx, app.Context, app.Context.InstanceProviders, app.Context.stopHooks)
+}
+
+// Started returns a channel which is closed when Start succeeds.
+func (app *App) Started() <-chan struct{} {
+	return app.channel(&app.started)
+}
+
+// Stopped returns a channel which is closed when Stop returns.
+func (app *App) Stopped() <-chan struct{} {
+	return app.channel(&app.stopped)
+}
+
+func (app *App) channel(ch *chan struct{}) chan struct{} {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if *ch == nil {
+		*ch = make(chan struct{})
+	}
+	return *ch
+}
+
+func (app *App) closeChannel(ch *chan struct{}) {
+	c := app.channel(ch)
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	select {
+	case <-c:
+	default:
+		close(c)
+	}
+}
+
+// StopTypes stops the services of given types and all their dependants in reverse order,
+// other services keep running.
+func (app *App) StopTypes(ctx context.Context, types ...reflect.Type) error {
+	c := app.Context
+	selected := map[*Provider]bool{}
+	providers := []*Provider{}
+
+	for _, p := range c.InstanceProviders {
+		ok := false
+		for _, typ := range types {
+			if p.Type == typ {
+				ok = true
+			}
+		}
+		for _, dep := range p.Deps {
+			depProviders, _, _ := c.resolve(p.Module, dep)
+			for _, depProvider := range depProviders {
+				if selected[depProvider] {
+					ok = true
+				}
+			}
+		}
 
-	// Find the services which implement the Stopper interface.
-	services := []Stopper{}
-	for _, instance := range app.Context.InstanceSlice {
-		service, ok := instance.(Stopper)
 		if ok {
-			services = append(services, service)
+			selected[p] = true
+			providers = append(providers, p)
 		}
 	}
 
+	return app.stop(ctx, c, providers, nil)
+}
+
+func (app *App) stop(ctx context.Context, c *Context, providers []*Provider, hooks []StopHook) error {
+	app.logVerbose("Stopping...")
+	providers = app.markStopped(providers)
+
 	// Close the services.
-	var err error = nil
-	for _, service := range services {
-		if stopErr := withTimeout(ctx, service.Stop); stopErr != nil {
-			if err == nil {
-				err = stopErr
-			}
+	var err error
+	if app.StopParallel {
+		err = app.stopParallel(ctx, c, providers)
+	} else {
+		err = app.stopSequential(ctx, c, providers)
+	}
+	app.checkReleased(c, providers)
+
+	// Run the stop hooks in reverse order.
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		hookErr := withTimeout(ctx, func() error { return hook(ctx) })
+		if hookErr != nil && err == nil {
+			err = hookErr
 		}
 	}
 
@@ -156,10 +511,231 @@ func (app *App) Stop(ctx context.Context) error {
 		return err
 	}
 
-	app.log("Stopped.")
+	app.logVerbose("Stopped.")
+	return nil
+}
+
+// markStopped records the providers as stopped and returns the ones which have not been stopped yet.
+func (app *App) markStopped(providers []*Provider) []*Provider {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if app.stoppedProviders == nil {
+		app.stoppedProviders = map[*Provider]bool{}
+	}
+
+	result := make([]*Provider, 0, len(providers))
+	for _, p := range providers {
+		if app.stoppedProviders[p] {
+			continue
+		}
+		app.stoppedProviders[p] = true
+		result = append(result, p)
+	}
+	return result
+}
+
+func (app *App) resetStopped() {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.stoppedProviders = nil
+}
+
+func (app *App) stopSequential(ctx context.Context, c *Context, providers []*Provider) error {
+	errs := []error{}
+	timedOut := false
+
+	for i := len(providers) - 1; i >= 0; i-- {
+		p := providers[i]
+		if _, ok := c.instances[p].(Stopper); !ok {
+			continue
+		}
+
+		err := app.stopService(ctx, c, p)
+		switch {
+		case err == nil:
+		case err == ctx.Err():
+			timedOut = true
+		default:
+			errs = append(errs, err)
+		}
+	}
+
+	switch {
+	case len(errs) > 0:
+		return errors.Join(errs...)
+	case timedOut:
+		return ctx.Err()
+	}
 	return nil
 }
 
+// stopParallel stops services in groups, the services in a group are stopped concurrently,
+// and all their dependants are stopped in the previous groups.
+func (app *App) stopParallel(ctx context.Context, c *Context, providers []*Provider) error {
+	// Compute the longest dependant path length for each provider.
+	levels := map[*Provider]int{}
+	for i := len(c.InstanceProviders) - 1; i >= 0; i-- {
+		p := c.InstanceProviders[i]
+		for _, dep := range p.Deps {
+			depProviders, _, _ := c.resolve(p.Module, dep)
+			for _, depProvider := range depProviders {
+				if levels[depProvider] < levels[p]+1 {
+					levels[depProvider] = levels[p] + 1
+				}
+			}
+		}
+	}
+
+	// Group the services by levels.
+	groups := [][]*Provider{}
+	for _, p := range providers {
+		if _, ok := c.instances[p].(Stopper); !ok {
+			continue
+		}
+
+		level := levels[p]
+		for len(groups) <= level {
+			groups = append(groups, nil)
+		}
+		groups[level] = append(groups[level], p)
+	}
+
+	// Stop the groups.
+	errs := []error{}
+	timedOut := false
+	mu := sync.Mutex{}
+
+	for _, group := range groups {
+		wg := sync.WaitGroup{}
+		for _, p := range group {
+			wg.Add(1)
+			go func(p *Provider) {
+				defer wg.Done()
+
+				err := app.stopService(ctx, c, p)
+				if err == nil {
+					return
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err == ctx.Err() {
+					timedOut = true
+				} else {
+					errs = append(errs, err)
+				}
+			}(p)
+		}
+		wg.Wait()
+	}
+
+	switch {
+	case len(errs) > 0:
+		return errors.Join(errs...)
+	case timedOut:
+		return ctx.Err()
+	}
+	return nil
+}
+
+// checkReleased logs the services which have not released their resources.
+func (app *App) checkReleased(c *Context, providers []*Provider) {
+	for _, p := range providers {
+		releaser, ok := c.instances[p].(Releaser)
+		if ok && !releaser.Released() {
+			app.log("Not released:", fmt.Sprintf("%T", releaser))
+		}
+	}
+}
+
+func (app *App) stopService(ctx context.Context, c *Context, p *Provider) error {
+	service := c.instances[p].(Stopper)
+	begin := app.now()
+	serviceCtx, cancel := withClockTimeout(ctx, app.Clock, app.serviceTimeout(p.Type).stop)
+	defer cancel()
+
+	err := withTimeout(serviceCtx, func() (err error) {
+		// Recover a panic, so that the other services are stopped.
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("di: stop panicked: %v", r)
+			}
+		}()
+		return service.Stop()
+	})
+	app.logEvent(PhaseStop, service, app.now().Sub(begin), err)
+	switch {
+	case err == nil:
+		return nil
+	case err == ctx.Err():
+		app.kill(service)
+		return err
+	case err == serviceCtx.Err():
+		app.kill(service)
+	}
+	return &LifecycleError{Service: p.Type, Phase: PhaseStop, Err: err}
+}
+
+// UnhealthyServices checks the services which implement the HealthChecker interface,
+// and returns the failing ones with their errors.
+func (app *App) UnhealthyServices() map[reflect.Type]error {
+	unhealthy := map[reflect.Type]error{}
+	for _, p := range app.Context.InstanceProviders {
+		checker, ok := app.Context.instances[p].(HealthChecker)
+		if !ok {
+			continue
+		}
+		if err := checker.Health(); err != nil {
+			unhealthy[p.Type] = err
+		}
+	}
+	return unhealthy
+}
+
+func (app *App) waitReady(ctx context.Context) error {
+	// Find the services which implement the HealthChecker interface.
+	checkers := []HealthChecker{}
+	for _, instance := range app.Context.InstanceSlice {
+		checker, ok := instance.(HealthChecker)
+		if ok {
+			checkers = append(checkers, checker)
+		}
+	}
+
+	for {
+		ready := true
+		for _, checker := range checkers {
+			if err := withTimeout(ctx, checker.Health); err != nil {
+				if err == ctx.Err() {
+					return err
+				}
+				ready = false
+				break
+			}
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-app.after(readyInterval):
+		}
+	}
+}
+
+func (app *App) kill(service Stopper) {
+	killer, ok := service.(Killer)
+	if !ok {
+		return
+	}
+
+	app.log("Killing", fmt.Sprintf("%T", service))
+	killer.Kill()
+}
+
 func (app *App) log(v ...interface{}) {
 	if app.Logger == nil {
 		return
@@ -167,6 +743,136 @@ func (app *App) log(v ...interface{}) {
 	app.Logger.Println(v...)
 }
 
+// logEvent logs a service start or stop, an event logger receives the failures as well.
+func (app *App) logEvent(phase string, service interface{}, d time.Duration, err error) {
+	name := fmt.Sprintf("%T", service)
+	logger, ok := app.Logger.(EventLogger)
+	switch {
+	case ok && (err != nil || app.Verbose):
+		event := LifecycleEvent{Phase: phase, Service: name, Status: StatusOK, Duration: d}
+		if err != nil {
+			event.Status = StatusError
+			event.Err = err
+		}
+		logger.LogEvent(event)
+
+	case !ok && err == nil && phase == PhaseStart:
+		app.logVerbose("Started", name)
+
+	case !ok && err == nil:
+		app.logVerbose("Stopped", name)
+	}
+}
+
+func (app *App) now() time.Time {
+	if app.Clock == nil {
+		return time.Now()
+	}
+	return app.Clock.Now()
+}
+
+func (app *App) after(d time.Duration) <-chan time.Time {
+	if app.Clock == nil {
+		return time.After(d)
+	}
+	return app.Clock.After(d)
+}
+
+// logVerbose logs a routine message when the app is verbose.
+func (app *App) logVerbose(v ...interface{}) {
+	if app.Verbose {
+		app.log(v...)
+	}
+}
+
+func hasStarters(c *Context) bool {
+	for _, instance := range c.InstanceSlice {
+		if _, ok := instance.(Starter); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func notifySignals() <-chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, os.Kill)
+	return ch
+}
+
+// timeoutContext returns a context with a timeout unless the parent context already has a deadline.
+func timeoutContext(parent context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := parent.Deadline(); ok {
+		return context.WithCancel(parent)
+	}
+	return withClockTimeout(parent, clock, timeout)
+}
+
+// withClockTimeout returns a context with a timeout measured by a clock, or by the system clock
+// when the clock is nil. A zero timeout is not applied.
+func withClockTimeout(parent context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	if clock == nil {
+		return context.WithTimeout(parent, timeout)
+	}
+
+	c := &clockContext{
+		Context:  parent,
+		deadline: clock.Now().Add(timeout),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-clock.After(timeout):
+			c.cancel(context.DeadlineExceeded)
+		case <-parent.Done():
+			c.cancel(parent.Err())
+		case <-c.done:
+		}
+	}()
+	return c, func() { c.cancel(context.Canceled) }
+}
+
+// clockContext is a context with a deadline measured by a clock.
+// It has its own done channel, so that the children are cancelled with its error.
+type clockContext struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *clockContext) Deadline() (time.Time, bool) {
+	if deadline, ok := c.Context.Deadline(); ok && deadline.Before(c.deadline) {
+		return deadline, true
+	}
+	return c.deadline, true
+}
+
+func (c *clockContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *clockContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *clockContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
+
 func withTimeout(ctx context.Context, fn func() error) error {
 	ch := make(chan error, 1)
 	go func() {
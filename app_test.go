@@ -2,7 +2,15 @@ package di
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -10,6 +18,7 @@ import (
 type testAppService struct {
 	started bool
 	stopped bool
+	stops   int
 }
 
 func (s *testAppService) Start() error {
@@ -19,9 +28,23 @@ func (s *testAppService) Start() error {
 
 func (s *testAppService) Stop() error {
 	s.stopped = true
+	s.stops++
 	return nil
 }
 
+func Test_SetDefaultTimeouts__should_set_timeouts_of_new_apps(t *testing.T) {
+	SetDefaultTimeouts(time.Second, 2*time.Second)
+	defer SetDefaultTimeouts(StartTimeout, StopTimeout)
+
+	app, err := NewApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, time.Second, app.StartTimeout)
+	assert.Equal(t, 2*time.Second, app.StopTimeout)
+}
+
 func Test_App_Start__should_start_services(t *testing.T) {
 	service := &testAppService{}
 	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
@@ -35,6 +58,21 @@ func Test_App_Start__should_start_services(t *testing.T) {
 	assert.True(t, service.started)
 }
 
+func Test_App_Start__should_return_error_on_cancelled_context(t *testing.T) {
+	service := &testAppService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = app.Start(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, service.started)
+}
+
 func Test_App_Stop__should_stop_services(t *testing.T) {
 	service := &testAppService{}
 	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
@@ -47,3 +85,951 @@ func Test_App_Stop__should_stop_services(t *testing.T) {
 
 	assert.True(t, service.stopped)
 }
+
+type testSlowService struct {
+	release chan struct{}
+	killed  bool
+}
+
+func (s *testSlowService) Stop() error {
+	<-s.release
+	return nil
+}
+
+func (s *testSlowService) Kill() {
+	s.killed = true
+	close(s.release)
+}
+
+func Test_App_Stop__should_kill_service_when_stop_times_out(t *testing.T) {
+	service := &testSlowService{release: make(chan struct{})}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = app.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, service.killed)
+}
+
+type testDrainingService struct {
+	stopped bool
+}
+
+func (s *testDrainingService) Stop() error {
+	time.Sleep(50 * time.Millisecond)
+	s.stopped = true
+	return nil
+}
+
+func Test_App_RunTask__should_wait_for_services_to_stop_with_zero_stop_timeout(t *testing.T) {
+	service := &testDrainingService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.StopTimeout = 0
+
+	err = app.RunTask(func(*Context) error { return nil })
+	assert.NoError(t, err)
+	assert.True(t, service.stopped)
+}
+
+type testReadyService struct {
+	readyAt time.Time
+}
+
+func (s *testReadyService) Start() error {
+	s.readyAt = time.Now().Add(50 * time.Millisecond)
+	return nil
+}
+
+func (s *testReadyService) Health() error {
+	if time.Now().Before(s.readyAt) {
+		return errors.New("not ready")
+	}
+	return nil
+}
+
+func Test_App_Start__should_wait_for_services_to_be_ready(t *testing.T) {
+	service := &testReadyService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.WaitReady = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = app.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, service.Health())
+}
+
+type testHealthService struct {
+	err error
+}
+
+func (s *testHealthService) Health() error {
+	return s.err
+}
+
+func Test_App_UnhealthyServices__should_return_failing_services(t *testing.T) {
+	healthy := &testHealthService{}
+	unhealthy := &testReadyService{readyAt: time.Now().Add(time.Hour)}
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(healthy)
+		m.AddInstance(unhealthy)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	services := app.UnhealthyServices()
+	assert.Equal(t, map[reflect.Type]error{
+		reflect.TypeOf(unhealthy): errors.New("not ready"),
+	}, services)
+}
+
+type testReloadDB struct {
+	testAppService
+	configured int
+}
+
+type testReloadServer struct {
+	testAppService
+	db         *testReloadDB
+	configured bool
+}
+
+func newTestReloadDB() *testReloadDB {
+	return &testReloadDB{}
+}
+
+func newTestReloadServer(db *testReloadDB) *testReloadServer {
+	return &testReloadServer{db: db}
+}
+
+func newTestReloadServer2(db *testReloadDB) *testReloadServer {
+	return &testReloadServer{db: db}
+}
+
+func configureTestReloadDB(db *testReloadDB) { db.configured++ }
+
+func configureTestReloadServer(s *testReloadServer) { s.configured = true }
+
+func testReloadModule0(m *Module) {
+	m.Add(newTestReloadDB)
+	m.Add(newTestReloadServer)
+	m.Invoke(configureTestReloadDB)
+	m.Invoke(configureTestReloadServer)
+}
+
+func testReloadModule1(m *Module) {
+	m.Add(newTestReloadDB)
+	m.Add(newTestReloadServer2)
+	m.Invoke(configureTestReloadDB)
+	m.Invoke(configureTestReloadServer)
+}
+
+func Test_App_Reload__should_restart_only_changed_services(t *testing.T) {
+	app, err := NewApp(testReloadModule0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var db0 *testReloadDB
+	var server0 *testReloadServer
+	app.Context.MustGet(&db0)
+	app.Context.MustGet(&server0)
+
+	if err = app.Reload(testReloadModule1); err != nil {
+		t.Fatal(err)
+	}
+
+	var db1 *testReloadDB
+	var server1 *testReloadServer
+	app.Context.MustGet(&db1)
+	app.Context.MustGet(&server1)
+
+	assert.Same(t, db0, db1)
+	assert.False(t, db1.stopped)
+	assert.Equal(t, 1, db1.configured)
+	assert.True(t, server0.stopped)
+	assert.NotSame(t, server0, server1)
+	assert.True(t, server1.started)
+	assert.True(t, server1.configured)
+	assert.Same(t, db0, server1.db)
+}
+
+func Test_App_Reload__should_restart_services_with_changed_instances(t *testing.T) {
+	service0 := &testAppService{}
+	service1 := &testAppService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service0) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = app.Reload(func(m *Module) { m.AddInstance(service1) }); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, service0.stopped)
+	assert.True(t, service1.started)
+}
+
+type testParallelDB struct {
+	stopped chan struct{}
+}
+
+func (s *testParallelDB) Stop() error {
+	close(s.stopped)
+	return nil
+}
+
+type testParallelAPI struct {
+	db      *testParallelDB
+	barrier *sync.WaitGroup
+	dbErr   error
+}
+
+func (s *testParallelAPI) Stop() error {
+	// Wait for the sibling service, it must be stopped concurrently.
+	s.barrier.Done()
+	s.barrier.Wait()
+
+	select {
+	case <-s.db.stopped:
+		s.dbErr = errors.New("db stopped before dependant")
+	default:
+	}
+	return nil
+}
+
+type testParallelWorker struct {
+	testParallelAPI
+}
+
+func Test_App_Stop__should_stop_independent_services_concurrently(t *testing.T) {
+	barrier := &sync.WaitGroup{}
+	barrier.Add(2)
+
+	db := &testParallelDB{stopped: make(chan struct{})}
+	api := &testParallelAPI{}
+	worker := &testParallelWorker{}
+
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(db)
+		m.Add(func(db *testParallelDB) *testParallelAPI {
+			api.db, api.barrier = db, barrier
+			return api
+		})
+		m.Add(func(db *testParallelDB) *testParallelWorker {
+			worker.db, worker.barrier = db, barrier
+			return worker
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.StopParallel = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = app.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, ctx.Err())
+	assert.NoError(t, api.dbErr)
+	assert.NoError(t, worker.dbErr)
+	select {
+	case <-db.stopped:
+	default:
+		t.Fatal("db is not stopped")
+	}
+}
+
+type testParallelFailing struct {
+	err error
+}
+
+func (s *testParallelFailing) Stop() error {
+	return s.err
+}
+
+type testParallelFailing2 struct {
+	testParallelFailing
+}
+
+func Test_App_Stop__should_aggregate_parallel_stop_errors(t *testing.T) {
+	err0 := errors.New("error 0")
+	err1 := errors.New("error 1")
+
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(&testParallelFailing{err: err0})
+		m.AddInstance(&testParallelFailing2{testParallelFailing{err: err1}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.StopParallel = true
+
+	err = app.Stop(context.Background())
+	assert.ErrorIs(t, err, err0)
+	assert.ErrorIs(t, err, err1)
+}
+
+func Test_App_RunTask__should_run_task_and_stop_services(t *testing.T) {
+	service := &testAppService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	taskErr := errors.New("task error")
+	err = app.RunTask(func(ctx *Context) error {
+		var s *testAppService
+		ctx.MustGet(&s)
+		assert.True(t, s.started)
+		return taskErr
+	})
+
+	assert.Equal(t, taskErr, err)
+	assert.True(t, service.stopped)
+}
+
+func Test_App_Run__should_start_and_stop_services_on_signal(t *testing.T) {
+	service := &testAppService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	app.Signals = func() <-chan os.Signal {
+		assert.True(t, service.started)
+		assert.False(t, service.stopped)
+
+		signals <- os.Interrupt
+		return signals
+	}
+
+	if err = app.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, service.started)
+	assert.True(t, service.stopped)
+}
+
+func Test_App_Run__should_capture_shutdown_signal(t *testing.T) {
+	app, err := NewApp(func(m *Module) { m.AddInstance(&testAppService{}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGTERM
+	app.Signals = func() <-chan os.Signal { return signals }
+
+	if err = app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, syscall.SIGTERM, app.ShutdownSignal())
+}
+
+func Test_App_Start__should_return_error_when_starters_are_required_but_absent(t *testing.T) {
+	app, err := NewApp(func(m *Module) { m.AddInstance("hello") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.RequireStarters = true
+
+	err = app.Start(context.Background())
+	assert.EqualError(t, err, "di: no services implement Starter")
+}
+
+func Test_App_RunContext__should_stop_on_context_cancellation_without_signals(t *testing.T) {
+	service := &testAppService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.DisableSignals = true
+	app.Signals = func() <-chan os.Signal {
+		t.Error("signals must not be registered")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err = app.RunContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, service.started)
+	assert.True(t, service.stopped)
+}
+
+type testBlockingStarter struct {
+	release chan struct{}
+}
+
+func (s *testBlockingStarter) Start() error {
+	<-s.release
+	return nil
+}
+
+func Test_App_Run__should_abort_start_when_base_context_is_cancelled(t *testing.T) {
+	service := &testBlockingStarter{release: make(chan struct{})}
+	defer close(service.release)
+
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	app.BaseContext = ctx
+
+	err = app.Run()
+	assert.Equal(t, context.Canceled, err)
+}
+
+func Test_App_Run__should_prefer_shorter_base_context_deadline(t *testing.T) {
+	service := &testBlockingStarter{release: make(chan struct{})}
+	defer close(service.release)
+
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	app.BaseContext = ctx
+	app.StartTimeout = time.Hour
+
+	started := time.Now()
+	err = app.Run()
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func Test_App_Run__should_ignore_start_timeout_when_base_context_has_deadline(t *testing.T) {
+	service := &testBlockingStarter{release: make(chan struct{})}
+	time.AfterFunc(20*time.Millisecond, func() { close(service.release) })
+
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	app.BaseContext = ctx
+	app.StartTimeout = time.Millisecond
+
+	err = app.RunTask(func(*Context) error { return nil })
+	assert.NoError(t, err)
+}
+
+type testCancellingStarter struct {
+	cancel context.CancelFunc
+}
+
+func (s *testCancellingStarter) Start() error {
+	s.cancel()
+	return errors.New("failed")
+}
+
+func Test_App_RunTask__should_roll_back_when_base_context_is_cancelled_during_start(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	service := &testAppService{}
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(service)
+		m.Add(func(*testAppService) *testCancellingStarter { return &testCancellingStarter{cancel: cancel} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.BaseContext = ctx
+
+	err = app.RunTask(func(*Context) error { return nil })
+	assert.Error(t, err)
+	assert.True(t, service.started)
+	assert.True(t, service.stopped)
+}
+
+type testClock struct {
+	fire chan time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return time.Time{}
+}
+
+func (c *testClock) After(time.Duration) <-chan time.Time {
+	return c.fire
+}
+
+func Test_App_RunTask__should_time_out_start_by_clock(t *testing.T) {
+	service := &testBlockingStarter{release: make(chan struct{})}
+	defer close(service.release)
+
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &testClock{fire: make(chan time.Time, 1)}
+	clock.fire <- time.Time{}
+	app.Clock = clock
+	app.StartTimeout = time.Hour
+
+	err = app.RunTask(func(*Context) error { return nil })
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+type testFlakyHealthService struct {
+	checks int
+}
+
+func (s *testFlakyHealthService) Health() error {
+	s.checks++
+	if s.checks == 1 {
+		return errors.New("not ready")
+	}
+	return nil
+}
+
+func Test_App_Start__should_poll_readiness_by_clock(t *testing.T) {
+	service := &testFlakyHealthService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &testClock{fire: make(chan time.Time, 1)}
+	clock.fire <- time.Time{}
+	app.Clock = clock
+	app.WaitReady = true
+
+	if err = app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, service.checks)
+	assert.Len(t, clock.fire, 0)
+}
+
+func Test_App_SetServiceTimeout__should_bound_service_start(t *testing.T) {
+	service := &testBlockingStarter{release: make(chan struct{})}
+	defer close(service.release)
+
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.SetServiceTimeout(reflect.TypeOf(service), 10*time.Millisecond, 0)
+
+	err = app.Start(context.Background())
+
+	var lifecycleErr *LifecycleError
+	if assert.ErrorAs(t, err, &lifecycleErr) {
+		assert.Equal(t, reflect.TypeOf(service), lifecycleErr.Service)
+	}
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_App_SetServiceTimeout__should_kill_service_when_its_stop_times_out(t *testing.T) {
+	service := &testSlowService{release: make(chan struct{})}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.SetServiceTimeout(reflect.TypeOf(service), 0, 10*time.Millisecond)
+
+	err = app.Stop(context.Background())
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, service.killed)
+}
+
+type testLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) Println(v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+type testLeakyService struct {
+	testAppService
+}
+
+func (s *testLeakyService) Released() bool {
+	return false
+}
+
+func Test_App_Stop__should_warn_about_services_which_are_not_released(t *testing.T) {
+	service := &testLeakyService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &testLogger{}
+	app.Logger = logger
+
+	if err = app.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, service.stopped)
+	assert.Contains(t, logger.lines, "Not released: *di.testLeakyService")
+}
+
+type testFailingStarter struct {
+	err error
+}
+
+func (s *testFailingStarter) Start() error {
+	return s.err
+}
+
+func Test_App_Start__should_log_only_failures_when_not_verbose(t *testing.T) {
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(&testAppService{})
+		m.AddInstance(&testFailingStarter{err: errors.New("start error")})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &testLogger{}
+	app.Logger = logger
+	app.Verbose = false
+
+	err = app.Start(context.Background())
+
+	assert.Error(t, err)
+	if assert.Len(t, logger.lines, 1) {
+		assert.Contains(t, logger.lines[0], "Failed to start:")
+	}
+}
+
+func Test_App_Start__should_log_routine_messages_when_verbose(t *testing.T) {
+	app, err := NewApp(func(m *Module) { m.AddInstance(&testAppService{}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &testLogger{}
+	app.Logger = logger
+
+	if err = app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"Starting...", "Started *di.testAppService", "Started."}, logger.lines)
+}
+
+func Test_App_Start__should_return_lifecycle_error_with_service_and_phase(t *testing.T) {
+	startErr := errors.New("start error")
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(&testFailingStarter{err: startErr})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.Start(context.Background())
+
+	var lifecycleErr *LifecycleError
+	if assert.True(t, errors.As(err, &lifecycleErr)) {
+		assert.Equal(t, reflect.TypeOf(&testFailingStarter{}), lifecycleErr.Service)
+		assert.Equal(t, PhaseStart, lifecycleErr.Phase)
+	}
+	assert.ErrorIs(t, err, startErr)
+}
+
+func Test_App_Stop__should_return_lifecycle_error_with_service_and_phase(t *testing.T) {
+	stopErr := errors.New("stop error")
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(&testParallelFailing{err: stopErr})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.Stop(context.Background())
+
+	var lifecycleErr *LifecycleError
+	if assert.True(t, errors.As(err, &lifecycleErr)) {
+		assert.Equal(t, reflect.TypeOf(&testParallelFailing{}), lifecycleErr.Service)
+		assert.Equal(t, PhaseStop, lifecycleErr.Phase)
+	}
+	assert.ErrorIs(t, err, stopErr)
+}
+
+type testOrderedStopper struct {
+	name  string
+	stops *[]string
+}
+
+func (s *testOrderedStopper) Stop() error {
+	*s.stops = append(*s.stops, s.name)
+	return nil
+}
+
+type testOrderedDependant struct {
+	testOrderedStopper
+}
+
+func Test_App_Stop__should_run_stop_hooks_after_stoppers_in_reverse_order(t *testing.T) {
+	stops := []string{}
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(&testOrderedStopper{name: "stopper", stops: &stops})
+		m.OnStop(func(ctx context.Context) error {
+			stops = append(stops, "hook0")
+			return nil
+		})
+		m.OnStop(func(ctx context.Context) error {
+			stops = append(stops, "hook1")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = app.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"stopper", "hook1", "hook0"}, stops)
+}
+
+func Test_App_Stop__should_stop_services_in_reverse_order(t *testing.T) {
+	stops := []string{}
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(&testOrderedStopper{name: "dependency", stops: &stops})
+		m.Add(func(dep *testOrderedStopper) *testOrderedDependant {
+			return &testOrderedDependant{testOrderedStopper{name: "dependant", stops: &stops}}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = app.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"dependant", "dependency"}, stops)
+}
+
+func Test_App_StopTypes__should_stop_services_and_their_dependants(t *testing.T) {
+	db := &testReloadDB{}
+	other := &testAppService{}
+
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(db)
+		m.AddInstance(other)
+		m.Add(newTestReloadServer)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server *testReloadServer
+	app.Context.MustGet(&server)
+
+	err = app.StopTypes(context.Background(), reflect.TypeOf(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, db.stopped)
+	assert.True(t, server.stopped)
+	assert.False(t, other.stopped)
+}
+
+func Test_App_Stop__should_skip_services_stopped_by_stop_types(t *testing.T) {
+	db := &testReloadDB{}
+	other := &testAppService{}
+
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(db)
+		m.AddInstance(other)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.StopTypes(context.Background(), reflect.TypeOf(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.Stop(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, db.stops)
+	assert.Equal(t, 1, other.stops)
+}
+
+func Test_App_Started__should_close_when_start_completes(t *testing.T) {
+	service := &testAppService{}
+	app, err := NewApp(func(m *Module) { m.AddInstance(service) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go app.Start(context.Background())
+
+	select {
+	case <-app.Started():
+	case <-time.After(time.Second):
+		t.Fatal("app is not started")
+	}
+
+	go app.Stop(context.Background())
+
+	select {
+	case <-app.Stopped():
+	case <-time.After(time.Second):
+		t.Fatal("app is not stopped")
+	}
+}
+
+type testRollbackService struct {
+	name     string
+	startErr error
+	stops    *[]string
+}
+
+func (s *testRollbackService) Start() error {
+	return s.startErr
+}
+
+func (s *testRollbackService) Stop() error {
+	*s.stops = append(*s.stops, s.name)
+	return nil
+}
+
+type testRollbackFirst struct{ testRollbackService }
+type testRollbackSecond struct{ testRollbackService }
+type testRollbackThird struct{ testRollbackService }
+type testRollbackFourth struct{ testRollbackService }
+
+func Test_App_RunTask__should_stop_only_started_services_on_start_failure(t *testing.T) {
+	stops := []string{}
+	app, err := NewApp(func(m *Module) {
+		m.Add(func() *testRollbackFirst {
+			return &testRollbackFirst{testRollbackService{name: "first", stops: &stops}}
+		})
+		m.Add(func(*testRollbackFirst) *testRollbackSecond {
+			return &testRollbackSecond{testRollbackService{name: "second", stops: &stops}}
+		})
+		m.Add(func(*testRollbackSecond) *testRollbackThird {
+			return &testRollbackThird{testRollbackService{
+				name: "third", startErr: errors.New("start error"), stops: &stops}}
+		})
+		m.Add(func(*testRollbackThird) *testRollbackFourth {
+			return &testRollbackFourth{testRollbackService{name: "fourth", stops: &stops}}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.RunTask(func(*Context) error { return nil })
+	assert.ErrorContains(t, err, "start error")
+	assert.Equal(t, []string{"second", "first"}, stops)
+}
+
+type testManagedHandler struct {
+	Service *testAppService
+	started bool
+}
+
+func (h *testManagedHandler) Start() error {
+	h.started = h.Service.started
+	return nil
+}
+
+func Test_Context_InjectAndManage__should_start_struct_with_app(t *testing.T) {
+	app, err := NewApp(func(m *Module) { m.AddInstance(&testAppService{}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := &testManagedHandler{}
+	if err := app.Context.InjectAndManage(handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotNil(t, handler.Service)
+	assert.True(t, handler.started)
+}
+
+func Test_App_Start__should_seal_context(t *testing.T) {
+	app, err := NewApp(func(m *Module) { m.AddInstance(&testAppService{}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.Context.Replace(&testAppService{})
+	assert.EqualError(t, err, "di: context is sealed")
+}
+
+type testPanickingStopper struct{}
+
+func (s *testPanickingStopper) Stop() error {
+	panic("stop failed")
+}
+
+func Test_App_Stop__should_continue_past_panicking_stopper(t *testing.T) {
+	first := &testAppService{}
+	last := &testRollbackFirst{testRollbackService{name: "last", stops: &[]string{}}}
+	app, err := NewApp(func(m *Module) {
+		m.AddInstance(first)
+		m.AddInstance(&testPanickingStopper{})
+		m.AddInstance(last)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.Stop(context.Background())
+
+	var lifecycleErr *LifecycleError
+	if assert.ErrorAs(t, err, &lifecycleErr) {
+		assert.Equal(t, reflect.TypeOf(&testPanickingStopper{}), lifecycleErr.Service)
+	}
+	assert.ErrorContains(t, err, "di: stop panicked: stop failed")
+	assert.True(t, first.stopped)
+	assert.Equal(t, []string{"last"}, *last.stops)
+}
@@ -0,0 +1,113 @@
+package di
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// BuildInfo describes when and from what a context was built.
+// It is provided by the context and can be injected into any provider.
+type BuildInfo struct {
+	Started   time.Time
+	Modules   int
+	Providers int
+}
+
+// BuildTimings are the durations of provider calls by provider types.
+// It can be injected into any provider and is filled while the context is built,
+// so it is complete after the build, for example, when a profiling service reports it.
+type BuildTimings map[reflect.Type]time.Duration
+
+// ModuleName is the name of a provider module.
+// It can be injected into any provider and resolves to the provider's own module name.
+type ModuleName string
+
+var moduleNameType = reflect.TypeOf(ModuleName(""))
+
+// ResolverFunc gets an instance by a type at runtime, it can be injected into any provider,
+// so that services can look up arbitrary types without holding the context.
+type ResolverFunc func(reflect.Type) (interface{}, bool)
+
+// contextType is injected with the build context, so that constructors honor the build deadline.
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// instancesType is injected into after-all providers with all built instances.
+var instancesType = reflect.TypeOf([]interface{}{})
+
+// Provider kinds.
+const (
+	KindFunc      = "func"
+	KindInstance  = "instance"
+	KindGroup     = "group"
+	KindTransient = "transient"
+)
+
+// ProviderInfo describes a provider for introspection.
+// A []ProviderInfo of all module providers can be injected into any provider.
+type ProviderInfo struct {
+	Name    string
+	Module  string
+	Type    reflect.Type
+	Deps    []reflect.Type
+	Kind    string
+	Private bool
+}
+
+// builtinModule provides the instances which are available to all modules.
+func (ctx *Context) builtinModule(m *Module) {
+	m.Add(ctx.buildInfo)
+	m.Add(ctx.providerInfos)
+	m.Add(func() BuildTimings { return ctx.timings })
+	m.Add(ctx.resolverFunc)
+}
+
+// resolverFunc returns a func which gets instances from this context, see ResolverFunc.
+func (ctx *Context) resolverFunc() ResolverFunc {
+	return ctx.GetByType
+}
+
+func (ctx *Context) buildInfo() BuildInfo {
+	return BuildInfo{
+		Started:   ctx.started,
+		Modules:   len(ctx.Modules) - 1,
+		Providers: len(ctx.Providers) - len(ctx.builtin.Providers),
+	}
+}
+
+// providerInfos returns the module providers ordered by modules and registration.
+func (ctx *Context) providerInfos() []ProviderInfo {
+	names := []string{}
+	for name, m := range ctx.Modules {
+		if m != ctx.builtin {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	infos := []ProviderInfo{}
+	for _, name := range names {
+		for _, p := range ctx.Modules[name].Providers {
+			kind := KindFunc
+			switch {
+			case p.Group:
+				kind = KindGroup
+			case p.IsInstance:
+				kind = KindInstance
+			case p.Transient:
+				kind = KindTransient
+			}
+
+			infos = append(infos, ProviderInfo{
+				Name:    p.Name,
+				Module:  p.Module.Name,
+				Type:    p.Type,
+				Deps:    append([]reflect.Type{}, p.Deps...),
+				Kind:    kind,
+				Private: p.Private,
+			})
+		}
+	}
+	return infos
+}
@@ -0,0 +1,238 @@
+package di
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BuildInfo__should_be_injectable_into_providers(t *testing.T) {
+	type Status struct {
+		Info BuildInfo
+	}
+
+	before := time.Now()
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.Add(func(info BuildInfo) *Status { return &Status{Info: info} })
+	}, func(m *Module) {
+		m.AddInstance(123)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var status *Status
+	ctx.MustGet(&status)
+
+	assert.Equal(t, 2, status.Info.Modules)
+	assert.Equal(t, 3, status.Info.Providers)
+	assert.False(t, status.Info.Started.Before(before))
+}
+
+func Test_ResolverFunc__should_be_injectable_into_providers(t *testing.T) {
+	type Dispatcher struct {
+		Resolve ResolverFunc
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.Add(func(resolve ResolverFunc) *Dispatcher {
+			return &Dispatcher{Resolve: resolve}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dispatcher *Dispatcher
+	ctx.MustGet(&dispatcher)
+
+	instance, ok := dispatcher.Resolve(reflect.TypeOf(""))
+	assert.True(t, ok)
+	assert.Equal(t, "hello", instance)
+
+	_, ok = dispatcher.Resolve(reflect.TypeOf(0))
+	assert.False(t, ok)
+}
+
+func Test_ResolverFunc__should_not_conflict_with_module_func_providers(t *testing.T) {
+	lookup := func(reflect.Type) (interface{}, bool) { return "custom", true }
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(lookup)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, _ := MustGet[func(reflect.Type) (interface{}, bool)](ctx)(reflect.TypeOf(""))
+	assert.Equal(t, "custom", instance)
+}
+
+type testPrefixLogger struct {
+	Prefix ModuleName
+}
+
+func newTestPrefixLogger(name ModuleName) *testPrefixLogger {
+	return &testPrefixLogger{Prefix: name}
+}
+
+type testUsersWorker struct{ Logger *testPrefixLogger }
+type testOrdersWorker struct{ Logger *testPrefixLogger }
+
+func testUsersModule(m *Module) {
+	m.AddPrivate(newTestPrefixLogger)
+	m.Add(func(logger *testPrefixLogger) *testUsersWorker { return &testUsersWorker{logger} })
+}
+
+func testOrdersModule(m *Module) {
+	m.AddPrivate(newTestPrefixLogger)
+	m.Add(func(logger *testPrefixLogger) *testOrdersWorker { return &testOrdersWorker{logger} })
+}
+
+func Test_ModuleName__should_be_injected_with_provider_module_name(t *testing.T) {
+	ctx, err := NewContext(testUsersModule, testOrdersModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users *testUsersWorker
+	var orders *testOrdersWorker
+	ctx.MustGet(&users)
+	ctx.MustGet(&orders)
+
+	assert.Equal(t, ModuleName("github.com/ivankorobkov/di.testUsersModule"), users.Logger.Prefix)
+	assert.Equal(t, ModuleName("github.com/ivankorobkov/di.testOrdersModule"), orders.Logger.Prefix)
+}
+
+type testDocsService struct {
+	Providers []ProviderInfo
+}
+
+func testDocsModule(m *Module) {
+	m.AddInstance("hello")
+	m.Add(func(providers []ProviderInfo) *testDocsService {
+		return &testDocsService{Providers: providers}
+	})
+}
+
+func Test_ProviderInfo__should_inject_provider_list(t *testing.T) {
+	ctx, err := NewContext(testDocsModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var docs *testDocsService
+	ctx.MustGet(&docs)
+
+	module := "github.com/ivankorobkov/di.testDocsModule"
+	if assert.Len(t, docs.Providers, 2) {
+		assert.Equal(t, ProviderInfo{
+			Name:   "string",
+			Module: module,
+			Type:   reflect.TypeOf(""),
+			Deps:   []reflect.Type{},
+			Kind:   KindInstance,
+		}, docs.Providers[0])
+		assert.Equal(t, ProviderInfo{
+			Name:   "github.com/ivankorobkov/di.testDocsModule.func1",
+			Module: module,
+			Type:   reflect.TypeOf(&testDocsService{}),
+			Deps:   []reflect.Type{reflect.TypeOf([]ProviderInfo{})},
+			Kind:   KindFunc,
+		}, docs.Providers[1])
+	}
+}
+
+func Test_ProviderInfo__should_mark_transient_providers(t *testing.T) {
+	var infos []ProviderInfo
+	ctx, err := NewContext(func(m *Module) {
+		m.AddTransient(func() int32 { return 1 })
+		m.Add(func(providers []ProviderInfo) string {
+			infos = providers
+			return "hello"
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.MustGet(new(string))
+
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, KindTransient, infos[0].Kind)
+		assert.Equal(t, KindFunc, infos[1].Kind)
+	}
+}
+
+func Test_NewContext__should_not_expose_builtin_instances(t *testing.T) {
+	type Monitor struct{ Instances []interface{} }
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.Add(func(info BuildInfo) int32 { return int32(info.Providers) })
+		m.AddAfterAll(func(instances []interface{}) *Monitor { return &Monitor{Instances: instances} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []interface{}{"hello", int32(3)}, MustGet[*Monitor](ctx).Instances)
+
+	var all []interface{}
+	assert.True(t, ctx.GetAll(&all))
+	assert.Len(t, all, 3)
+	assert.NotContains(t, ctx.MarshalDot(), "di.BuildInfo")
+	assert.NotContains(t, MustGet[BuildTimings](ctx), reflect.TypeOf(BuildInfo{}))
+}
+
+type testProfiler struct {
+	Timings BuildTimings
+}
+
+func Test_BuildTimings__should_contain_provider_durations_after_build(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(func(timings BuildTimings) *testProfiler { return &testProfiler{Timings: timings} })
+		m.Add(func() int32 { time.Sleep(time.Millisecond); return 1 })
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var profiler *testProfiler
+	ctx.MustGet(&profiler)
+
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf(&testProfiler{}),
+		reflect.TypeOf(int32(0)),
+		reflect.TypeOf(""),
+	} {
+		if assert.Contains(t, profiler.Timings, typ) {
+			assert.GreaterOrEqual(t, profiler.Timings[typ], time.Duration(0))
+		}
+	}
+	assert.GreaterOrEqual(t, profiler.Timings[reflect.TypeOf(int32(0))], time.Millisecond)
+}
+
+func Test_BuildTimings__should_contain_provider_durations_without_build_context(t *testing.T) {
+	module := func(m *Module) {
+		m.Add(func(timings BuildTimings) *testProfiler { return &testProfiler{Timings: timings} })
+		m.AddInstance("hello")
+	}
+
+	ctx, err := NewContextWithOptions(Options{}, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, MustGet[*testProfiler](ctx).Timings, reflect.TypeOf(""))
+
+	scope, err := ctx.NewScope(func(m *Module) {
+		m.AddInstance(int64(1))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, MustGet[BuildTimings](scope), reflect.TypeOf(int64(0)))
+}
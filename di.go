@@ -1,20 +1,111 @@
 package di
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+	"unsafe"
 )
 
 // Context is a dependency injection context.
 type Context struct {
-	Modules       map[string]*Module
-	Providers     map[reflect.Type]*Provider
-	Instances     map[reflect.Type]interface{}
-	InstanceSlice []interface{} // Ordered from dependencies to dependants.
+	Modules           map[string]*Module
+	Providers         map[reflect.Type]*Provider
+	Instances         map[reflect.Type]interface{}
+	InstanceSlice     []interface{} // Module instances ordered from dependencies to dependants.
+	InstanceProviders []*Provider   // Providers of InstanceSlice, in the same order.
+	Resolvers         []Resolver    // Resolve the types without providers, in registration order.
+
+	options   Options                   // Build options, reused by reloads.
+	build     context.Context           // Build context, aborts the construction when done.
+	builtin   *Module                   // Provides the context instances to all modules.
+	groups    []*Provider               // Group providers ordered by modules and registration.
+	stopHooks []StopHook                // Module stop hooks in registration order.
+	invokes   []*Provider               // Module invokes in registration order.
+	started   time.Time                 // Context build start time.
+	instances map[*Provider]interface{} // Instances by providers, including private ones.
+	timings   BuildTimings              // Provider call durations during builds and reloads.
+	reused    map[*Provider]interface{} // Instances to reuse instead of calling providers.
+	sealed    bool                      // Rejects mutations.
+	building  bool                      // Records provider call timings during builds and reloads.
+	depth     int                       // Resolution depth of the trace.
+	named     map[namedKey]*Provider    // Named binding providers by types and names.
+	proxies   map[*Provider]*Provider   // Built-in providers of the parent providers of a scope.
+}
+
+// errSealed is returned by the mutating methods of a sealed context.
+var errSealed = errors.New("di: context is sealed")
+
+// errNoInstance is returned when a context has no instance of a type.
+var errNoInstance = errors.New("di: no instance")
+
+// Options configure a context build.
+type Options struct {
+	// Build aborts the construction when it is cancelled or times out, defaults to no context.
+	Build context.Context
+
+	// PanicFree makes NewContextWithOptions return module and provider registration errors,
+	// for example, a non-function provider, instead of panicking.
+	PanicFree bool
+
+	// SeedOrder makes the types to be constructed first in the given order, still after
+	// their dependencies, for example, to reproduce order-dependent bugs.
+	SeedOrder []reflect.Type
+
+	// Parallel makes independent providers to be called concurrently, the first error cancels
+	// the build context of the in-flight providers. SeedOrder is ignored.
+	Parallel bool
+
+	// Overrides are modules whose providers supersede the providers of the same types
+	// instead of failing with duplicate provider errors, for example, fakes in tests.
+	Overrides []ModuleFunc
+
+	// Lazy makes instances to be built on the first get or injection, and memoized, instead of
+	// building all of them at once. Lazy gets are not safe for concurrent use, an app manages
+	// only the built instances. Parallel and SeedOrder are ignored.
+	Lazy bool
+
+	parent *Context // Parent of a scope, see Context.NewScope.
+
+	// Trace writes the resolution walk of a sequential build, for example,
+	// "resolving *Server (needs *DB, *Config)" and "built *Server in 1ms".
+	Trace io.Writer
+
+	// WarningPolicy configures whether the build diagnostics by categories are logged, ignored
+	// or abort the build, for example, {WarningUnusedProviders: PolicyError}. Defaults to PolicyWarn.
+	WarningPolicy map[string]WarningPolicy
+
+	// Logger logs the build warnings.
+	Logger Logger
 }
 
+// WarningPolicy is a build diagnostic policy.
+type WarningPolicy int
+
+const (
+	PolicyWarn   WarningPolicy = iota // Log a diagnostic, the default.
+	PolicyIgnore                      // Skip a diagnostic.
+	PolicyError                       // Abort the build.
+)
+
+// Build diagnostic categories.
+const (
+	// WarningUnusedProviders reports the providers which are not reachable from the Starter
+	// and Stopper instances or the module invokes.
+	WarningUnusedProviders = "unused-providers"
+
+	// WarningTransientDeps reports the Starter and Stopper instances which transitively depend
+	// on transient providers, whose lifecycle is ambiguous.
+	WarningTransientDeps = "transient-deps"
+)
+
 // Inject creates a context and injects dependencies into public struct fields.
 func Inject(dstPtr interface{}, mfuncs ...ModuleFunc) error {
 	ctx, err := NewContext(mfuncs...)
@@ -22,8 +113,7 @@ func Inject(dstPtr interface{}, mfuncs ...ModuleFunc) error {
 		return err
 	}
 
-	ctx.Inject(dstPtr)
-	return nil
+	return ctx.Inject(dstPtr)
 }
 
 // MustInject creates a context and injects dependencies into public struct fields, or panics on an error.
@@ -33,21 +123,84 @@ func MustInject(dstPtr interface{}, mfuncs ...ModuleFunc) {
 	}
 }
 
+// CheckAcyclic checks that module imports and provider dependencies form a DAG
+// without calling any providers, and returns the cycle path if any.
+func CheckAcyclic(mfuncs ...ModuleFunc) error {
+	_, err := newContext(Options{}, mfuncs)
+	return err
+}
+
 // NewContext creates a context and initializes all instances from its providers.
 func NewContext(mfuncs ...ModuleFunc) (*Context, error) {
+	return NewContextWithContext(context.Background(), mfuncs...)
+}
+
+// NewContextWithContext creates a context and initializes all instances from its providers,
+// the construction is aborted when the build context is cancelled or times out.
+// Providers which depend on context.Context receive the build context.
+func NewContextWithContext(build context.Context, mfuncs ...ModuleFunc) (*Context, error) {
+	return NewContextWithOptions(Options{Build: build}, mfuncs...)
+}
+
+// NewContextWithOptions creates a context with build options and initializes all instances from its providers.
+func NewContextWithOptions(opts Options, mfuncs ...ModuleFunc) (*Context, error) {
+	ctx, err := newContext(opts, mfuncs)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.build = opts.Build
+	ctx.building = true
+	if !opts.Lazy {
+		err = ctx.initInstances()
+	}
+	if err == nil {
+		err = ctx.initInvokes(nil)
+	}
+	if err == nil {
+		err = ctx.checkWarnings()
+	}
+	ctx.build = nil
+	ctx.building = false
+	if err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// newContext creates a context and initializes its modules and providers, but not instances.
+func newContext(opts Options, mfuncs []ModuleFunc) (*Context, error) {
 	ctx := &Context{
+		options:   opts,
 		Modules:   make(map[string]*Module),
 		Providers: make(map[reflect.Type]*Provider),
 		Instances: make(map[reflect.Type]interface{}),
+		started:   time.Now(),
+		instances: make(map[*Provider]interface{}),
+		timings:   make(BuildTimings),
+		named:     make(map[namedKey]*Provider),
 	}
 
+	builtin, err := ctx.initModule(ctx.builtinModule, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx.builtin = builtin
+
 	if err := ctx.initModules(mfuncs); err != nil {
 		return nil, err
 	}
+	for _, mfunc := range opts.Overrides {
+		m, err := ctx.initModule(mfunc, nil)
+		if err != nil {
+			return nil, err
+		}
+		m.override = true
+	}
 	if err := ctx.initProviders(); err != nil {
 		return nil, err
 	}
-	if err := ctx.initInstances(); err != nil {
+	if err := ctx.checkAcyclic(); err != nil {
 		return nil, err
 	}
 	return ctx, nil
@@ -56,7 +209,7 @@ func NewContext(mfuncs ...ModuleFunc) (*Context, error) {
 // Get returns an instance from this context of a given type.
 func (ctx *Context) Get(dstPtr interface{}) bool {
 	t := reflect.TypeOf(dstPtr).Elem()
-	instance, ok := ctx.Instances[t]
+	instance, ok := ctx.GetByType(t)
 	if !ok {
 		return false
 	}
@@ -66,165 +219,1617 @@ func (ctx *Context) Get(dstPtr interface{}) bool {
 	return true
 }
 
+// GetByType returns an instance from this context of a given reflect type.
+// An evicted releasable instance, a transient instance, or an instance of a lazy context is built on demand,
+// false is returned when its provider fails, MustGet reports the provider error.
+func (ctx *Context) GetByType(typ reflect.Type) (interface{}, bool) {
+	instance, err := ctx.getByType(typ)
+	return instance, err == nil
+}
+
+// getByType returns an instance of a given type and builds it on demand,
+// or returns errNoInstance or the provider error.
+func (ctx *Context) getByType(typ reflect.Type) (interface{}, error) {
+	instance, ok := ctx.Instances[typ]
+	if ok {
+		return instance, nil
+	}
+
+	p, ok := ctx.Providers[typ]
+	switch {
+	case ok && p.AfterAll && ctx.options.Lazy:
+		// An after-all provider receives all instances, build them first.
+		if err := ctx.initAll(); err != nil {
+			return nil, err
+		}
+		return ctx.initInstance(p)
+	case ok && (p.Releasable || p.Transient || ctx.options.Lazy):
+		return ctx.initInstance(p)
+	case !ok && ctx.options.parent != nil:
+		return ctx.options.parent.getByType(typ)
+	}
+	return nil, errNoInstance
+}
+
+// Evict removes the instance of a releasable provider and stops it when it implements the Stopper
+// interface, like Replace, the instance is rebuilt on the next get. The instances which depend
+// on the evicted one keep it.
+func (ctx *Context) Evict(typ reflect.Type) error {
+	if ctx.sealed {
+		return errSealed
+	}
+
+	p, ok := ctx.Providers[typ]
+	switch {
+	case !ok:
+		return fmt.Errorf("di: no provider, type=%v", typ)
+	case p.Transient:
+		return fmt.Errorf("di: provider is transient, type=%v", typ)
+	case !p.Releasable:
+		return fmt.Errorf("di: provider is not releasable, type=%v", typ)
+	}
+
+	old := ctx.instances[p]
+	delete(ctx.instances, p)
+	delete(ctx.Instances, typ)
+	for i, p1 := range ctx.InstanceProviders {
+		if p1 == p {
+			ctx.InstanceSlice = append(ctx.InstanceSlice[:i:i], ctx.InstanceSlice[i+1:]...)
+			ctx.InstanceProviders = append(ctx.InstanceProviders[:i:i], ctx.InstanceProviders[i+1:]...)
+			break
+		}
+	}
+
+	if stopper, ok := old.(Stopper); ok {
+		return stopper.Stop()
+	}
+	return nil
+}
+
+// GetAs sets an interface to the instance whose concrete type is a given hint, when several
+// instances implement the interface, for example, ctx.GetAs(&store, reflect.TypeOf(&PostgresStore{})).
+// A lazy context builds all instances first.
+func (ctx *Context) GetAs(ifacePtr interface{}, hint reflect.Type) bool {
+	if err := ctx.initLazy(); err != nil {
+		return false
+	}
+
+	dst := reflect.ValueOf(ifacePtr).Elem()
+	for i, p := range ctx.InstanceProviders {
+		instance := ctx.InstanceSlice[i]
+		if p.Private || isNil(instance) || reflect.TypeOf(instance) != hint {
+			continue
+		}
+		if !hint.AssignableTo(dst.Type()) {
+			return false
+		}
+
+		dst.Set(reflect.ValueOf(instance))
+		return true
+	}
+	return false
+}
+
+// HasInterface returns true when an interface is bound to a provider, for example, with AddAs,
+// the interface is passed as a nil pointer, for example, (*Service)(nil).
+func (ctx *Context) HasInterface(ifacePtr interface{}) bool {
+	ptr := reflect.TypeOf(ifacePtr)
+	if ptr == nil || ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Interface {
+		return false
+	}
+
+	_, ok := ctx.Providers[ptr.Elem()]
+	return ok
+}
+
+// AllInstances returns a shallow copy of the instances by types,
+// so that callers cannot mutate the context.
+func (ctx *Context) AllInstances() map[reflect.Type]interface{} {
+	instances := make(map[reflect.Type]interface{}, len(ctx.Instances))
+	for typ, instance := range ctx.Instances {
+		instances[typ] = instance
+	}
+	return instances
+}
+
+// GetAll sets a slice to all instances from this context which are assignable
+// to the slice element type, including group instances, in the construction order.
+// A lazy context builds all instances first.
+func (ctx *Context) GetAll(dstSlicePtr interface{}) bool {
+	dst := reflect.ValueOf(dstSlicePtr).Elem()
+	instances := ctx.assignableInstances(dst.Type().Elem())
+	if len(instances) == 0 {
+		return false
+	}
+
+	slice := reflect.MakeSlice(dst.Type(), 0, len(instances))
+	for _, instance := range instances {
+		slice = reflect.Append(slice, reflect.ValueOf(instance))
+	}
+	dst.Set(slice)
+	return true
+}
+
 // GetMust returns an instance from this context of a given type or panics if absents.
 func (ctx *Context) MustGet(dstPtr interface{}) {
-	if !ctx.Get(dstPtr) {
+	instance, err := ctx.getByType(reflect.TypeOf(dstPtr).Elem())
+	switch {
+	case err == errNoInstance:
 		panic(fmt.Sprintf("di: no instance, type=%T", dstPtr))
+	case err != nil:
+		panic(fmt.Sprintf("di: no instance, type=%T, err=%v", dstPtr, err))
 	}
+	reflect.ValueOf(dstPtr).Elem().Set(reflect.ValueOf(instance))
 }
 
-// Inject injects dependencies into public struct fields.
-func (ctx *Context) Inject(structPtr interface{}) {
-	v := reflect.ValueOf(structPtr).Elem()
+// Get returns an instance of type T from a context, for example, di.Get[*Server](ctx).
+func Get[T any](ctx *Context) (T, bool) {
+	var zero T
+	instance, ok := ctx.GetByType(reflect.TypeOf((*T)(nil)).Elem())
+	if !ok {
+		return zero, false
+	}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		ftype := field.Type()
-		instance, ok := ctx.Instances[ftype]
-		if !ok {
-			continue
-		}
+	t, ok := instance.(T)
+	return t, ok
+}
 
-		field.Set(reflect.ValueOf(instance))
+// MustGet returns an instance of type T from a context or panics if absents.
+func MustGet[T any](ctx *Context) T {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	instance, err := ctx.getByType(typ)
+	switch {
+	case err == errNoInstance:
+		panic(fmt.Sprintf("di: no instance, type=%v", typ))
+	case err != nil:
+		panic(fmt.Sprintf("di: no instance, type=%v, err=%v", typ, err))
 	}
+
+	t, ok := instance.(T)
+	if !ok {
+		panic(fmt.Sprintf("di: no instance, type=%v", typ))
+	}
+	return t
 }
 
-func (ctx *Context) initModules(mfuncs []ModuleFunc) error {
-	for _, mfunc := range mfuncs {
-		prevNames := []string{}
-		if _, err := ctx.initModule(mfunc, prevNames); err != nil {
+// Invoke calls a function with parameters injected from this context, and returns the function error,
+// an unresolved parameter error or an invalid function error. The function returns nothing or an error.
+func (ctx *Context) Invoke(f interface{}) error {
+	p, err := parseInvoker(ctx.builtin, f)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{}
+	for _, dep := range p.Deps {
+		arg, err := ctx.initDep(p, dep)
+		if err != nil {
 			return err
 		}
+		args = append(args, arg)
+	}
+
+	_, err = p.Func(args)
+	return err
+}
+
+// MustInvoke calls a function with parameters injected from this context, or panics on an error.
+func (ctx *Context) MustInvoke(f interface{}) {
+	if err := ctx.Invoke(f); err != nil {
+		panic(err)
 	}
+}
+
+// Clone returns a copy of this context with independent instance maps and slices.
+// Modules and providers are shared, and instances themselves are shared references.
+func (ctx *Context) Clone() *Context {
+	clone := &Context{
+		Modules:           ctx.Modules,
+		Providers:         ctx.Providers,
+		Instances:         make(map[reflect.Type]interface{}, len(ctx.Instances)),
+		InstanceSlice:     append([]interface{}(nil), ctx.InstanceSlice...),
+		InstanceProviders: append([]*Provider(nil), ctx.InstanceProviders...),
+
+		options:   ctx.options,
+		builtin:   ctx.builtin,
+		groups:    ctx.groups,
+		named:     ctx.named,
+		stopHooks: ctx.stopHooks,
+		started:   ctx.started,
+		instances: make(map[*Provider]interface{}, len(ctx.instances)),
+		timings:   ctx.timings,
+	}
+	for typ, instance := range ctx.Instances {
+		clone.Instances[typ] = instance
+	}
+	for p, instance := range ctx.instances {
+		clone.instances[p] = instance
+	}
+	return clone
+}
+
+// SetSeedOrder sets the construction order hint for the rebuilds of this context, for example,
+// by App.Reload. Use Options.SeedOrder for the initial build.
+func (ctx *Context) SetSeedOrder(types []reflect.Type) error {
+	if ctx.sealed {
+		return errSealed
+	}
+	ctx.options.SeedOrder = append([]reflect.Type{}, types...)
 	return nil
 }
 
-func (ctx *Context) initModule(mfunc ModuleFunc, prevNames []string) (*Module, error) {
-	name := mfunc.Name()
-	if m, ok := ctx.Modules[name]; ok {
-		return m, nil
+// Seal marks this context immutable, so that its mutating methods return an error.
+// An app seals its context on start.
+func (ctx *Context) Seal() {
+	ctx.sealed = true
+}
+
+// Replace replaces the instance of its type provider in place, and stops the old instance
+// when it implements the Stopper interface. The instances which depend on the old one keep it.
+func (ctx *Context) Replace(instance interface{}) error {
+	if ctx.sealed {
+		return errSealed
 	}
 
-	// Prevent cyclic imports.
-	{
-		path := []string{name}
-		for i := len(prevNames) - 1; i >= 0; i-- {
-			prev := prevNames[i]
-			path = append(path, prev)
+	typ := reflect.TypeOf(instance)
+	p, ok := ctx.Providers[typ]
+	switch {
+	case !ok:
+		return fmt.Errorf("di: no provider, type=%v", typ)
+	case p.Transient:
+		return fmt.Errorf("di: provider is transient, type=%v", typ)
+	}
 
-			if prev == name {
-				return nil, fmt.Errorf("di: cyclic import %v", strings.Join(path, " -> "))
-			}
+	old := ctx.instances[p]
+	ctx.instances[p] = instance
+	ctx.Instances[typ] = instance
+	for i, p1 := range ctx.InstanceProviders {
+		if p1 == p {
+			ctx.InstanceSlice[i] = instance
 		}
 	}
-	prevNames = append(prevNames, name)
 
-	// Start module initialization.
-	m := newModule(mfunc)
+	if stopper, ok := old.(Stopper); ok {
+		return stopper.Stop()
+	}
+	return nil
+}
 
-	// Resolve imported modules.
-	for _, impfunc := range m.Imports {
-		if _, err := ctx.initModule(impfunc, prevNames); err != nil {
-			return nil, err
+// Inject injects dependencies into public struct fields. Fields tagged `di:"-"` are skipped,
+// fields tagged `di:"required"` must be injected, and the others, e.g. `di:"optional"`, are injected
+// when there are instances. Fields tagged `di:"name=replica"` are injected with named bindings,
+// the options are combined with commas. It returns an error listing the unfulfilled required fields.
+func (ctx *Context) Inject(structPtr interface{}) error {
+	v := reflect.ValueOf(structPtr).Elem()
+	t := v.Type()
+
+	unfulfilled := []string{}
+	for i := 0; i < v.NumField(); i++ {
+		sf := t.Field(i)
+		tag := parseInjectTag(sf.Tag)
+		if tag.skip || !sf.IsExported() {
+			continue
+		}
+
+		var ok bool
+		if tag.name != "" {
+			ok = ctx.injectNamed(v.Field(i), tag.name)
+		} else {
+			ok = ctx.injectField(v.Field(i))
+		}
+		if !ok && tag.required {
+			unfulfilled = append(unfulfilled, sf.Name)
 		}
 	}
 
-	// Add the initialized module to the context.
-	ctx.Modules[name] = m
-	return m, nil
+	if len(unfulfilled) > 0 {
+		return fmt.Errorf("di: unfulfilled required fields, type=%v, fields=%v",
+			t, strings.Join(unfulfilled, ", "))
+	}
+	return nil
 }
 
-func (ctx *Context) initProviders() error {
-	// Add providers to the package, prevent duplicates.
-	for _, m := range ctx.Modules {
-		for _, p := range m.Providers {
-			if p1, ok := ctx.Providers[p.Type]; ok {
-				return fmt.Errorf("di: duplicate provider, type=%v, module0=%v, module1=%v",
-					p.Type, p.Module.Name, p1.Module.Name)
-			}
+// injectTag is a parsed `di` struct tag, for example, `di:"name=replica,required"`.
+type injectTag struct {
+	skip     bool
+	required bool
+	name     string
+}
 
-			ctx.Providers[p.Type] = p
+func parseInjectTag(tag reflect.StructTag) injectTag {
+	t := injectTag{}
+	for _, opt := range strings.Split(tag.Get("di"), ",") {
+		switch {
+		case opt == "-":
+			t.skip = true
+		case opt == "required":
+			t.required = true
+		case strings.HasPrefix(opt, "name="):
+			t.name = strings.TrimPrefix(opt, "name=")
 		}
 	}
+	return t
+}
 
-	// Check provider dependencies.
-	for _, m := range ctx.Modules {
-		availableDeps := map[reflect.Type]bool{}
+// injectField injects an instance into a struct field, and returns false when there is no instance.
+func (ctx *Context) injectField(field reflect.Value) bool {
+	ftype := field.Type()
+	instance, ok := ctx.GetByType(ftype)
+	if ok {
+		field.Set(reflect.ValueOf(instance))
+		return true
+	}
 
-		// Add providers from the imported modules.
-		for _, imp := range m.Imports {
-			impModule := ctx.Modules[imp.Name()]
-			for _, dep := range impModule.Providers {
-				availableDeps[dep.Type] = true
-			}
+	// Allocate a pointer to an interface and assign an interface instance.
+	if ftype.Kind() == reflect.Ptr && ftype.Elem().Kind() == reflect.Interface {
+		instance, ok := ctx.GetByType(ftype.Elem())
+		if !ok {
+			return false
 		}
 
-		// Add this module providers.
-		for _, p := range m.Providers {
-			availableDeps[p.Type] = true
-		}
+		ptr := reflect.New(ftype.Elem())
+		ptr.Elem().Set(reflect.ValueOf(instance))
+		field.Set(ptr)
+		return true
+	}
 
-		// Add existing explicit dependencies.
-		for _, dep := range m.Deps {
-			_, ok := ctx.Providers[dep]
-			if ok {
-				availableDeps[dep] = true
-			}
+	// Fill an array when there are exactly as many assignable instances as its length.
+	if ftype.Kind() == reflect.Array {
+		instances := ctx.assignableInstances(ftype.Elem())
+		if len(instances) != ftype.Len() {
+			return false
 		}
 
-		// Check provider dependencies.
-		for _, p := range m.Providers {
-			for _, dep := range p.Deps {
-				if _, ok := availableDeps[dep]; !ok {
-					return fmt.Errorf(
-						"di: unresolved provider dependency, dep=%v, provider=%v, module=%v",
-						dep, p, m.Name)
-				}
-			}
+		for j, instance := range instances {
+			field.Index(j).Set(reflect.ValueOf(instance))
 		}
+		return true
 	}
-
-	return nil
+	return false
 }
 
-func (ctx *Context) initInstances() error {
-	for _, p := range ctx.Providers {
-		if _, err := ctx.initInstance(p.Type); err != nil {
+// InjectUnsafe injects dependencies into struct fields tagged `di:"inject"`, including unexported ones,
+// it uses unsafe to bypass the reflect restrictions, so it is gated behind a distinct method.
+func (ctx *Context) InjectUnsafe(structPtr interface{}) error {
+	v := reflect.ValueOf(structPtr).Elem()
+	t := v.Type()
+
+	for i := 0; i < v.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Tag.Get("di") != "inject" {
+			continue
+		}
+
+		instance, err := ctx.getByType(sf.Type)
+		switch {
+		case err == errNoInstance:
+			return fmt.Errorf("di: no instance, type=%v, field=%v", sf.Type, sf.Name)
+		case err != nil:
 			return err
 		}
+
+		field := v.Field(i)
+		field = reflect.NewAt(sf.Type, unsafe.Pointer(field.UnsafeAddr())).Elem()
+		field.Set(reflect.ValueOf(instance))
 	}
 	return nil
 }
 
-func (ctx *Context) initInstance(typ reflect.Type) (interface{}, error) {
-	instance, ok := ctx.Instances[typ]
-	if ok {
-		return instance, nil
+// InjectAndManage injects dependencies into public struct fields, and adds the struct to the context
+// instances, so that an app starts and stops it when it implements the Starter or Stopper interface.
+// The struct is started after and stopped before all other instances, its injected fields are
+// context instances and are managed by the app anyway. Call it before the app starts.
+func (ctx *Context) InjectAndManage(structPtr interface{}) error {
+	if ctx.sealed {
+		return errSealed
+	}
+	if err := ctx.Inject(structPtr); err != nil {
+		return err
 	}
 
-	p, ok := ctx.Providers[typ]
-	if !ok {
-		return nil, fmt.Errorf("di: no provider, type=%v", typ)
+	typ := reflect.TypeOf(structPtr)
+	p := &Provider{
+		Module: ctx.builtin,
+		Name:   typ.String(),
+		Type:   typ,
+		Impl:   typ,
+		Deps:   []reflect.Type{},
+		Func: func([]interface{}) (interface{}, error) {
+			return structPtr, nil
+		},
+		IsInstance: true,
+		Private:    true,
 	}
+	ctx.instances[p] = structPtr
+	ctx.InstanceSlice = append(ctx.InstanceSlice, structPtr)
+	ctx.InstanceProviders = append(ctx.InstanceProviders, p)
+	return nil
+}
 
-	args := []interface{}{}
-	for _, dep := range p.Deps {
-		arg, err := ctx.initInstance(dep)
-		if err != nil {
-			return nil, err
+// InjectAll injects dependencies into public fields of multiple structs, and returns an error
+// listing the targets which are not struct pointers, the valid targets are injected anyway.
+func (ctx *Context) InjectAll(structPtrs ...interface{}) error {
+	invalid := []string{}
+	errs := []error{}
+	for _, ptr := range structPtrs {
+		v := reflect.ValueOf(ptr)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			invalid = append(invalid, fmt.Sprintf("%T", ptr))
+			continue
+		}
+		if err := ctx.Inject(ptr); err != nil {
+			errs = append(errs, err)
 		}
+	}
 
-		args = append(args, arg)
+	if len(invalid) > 0 {
+		return fmt.Errorf("di: not struct pointers, types=%v", strings.Join(invalid, ", "))
 	}
+	return errors.Join(errs...)
+}
 
-	instance, err := p.Func(args)
-	if err != nil {
-		return nil, err
+// assignableInstances returns the non-nil context instances in the construction order,
+// which are assignable to a given type. A lazy context builds all instances first,
+// and returns none when a provider fails.
+func (ctx *Context) assignableInstances(typ reflect.Type) []interface{} {
+	result := []interface{}{}
+	if err := ctx.initLazy(); err != nil {
+		return result
 	}
 
-	ctx.Instances[typ] = instance
-	ctx.InstanceSlice = append(ctx.InstanceSlice, instance)
-	return instance, nil
+	for i, p := range ctx.InstanceProviders {
+		instance := ctx.InstanceSlice[i]
+		if p.Private || isNil(instance) || !p.Type.AssignableTo(typ) {
+			continue
+		}
+		result = append(result, instance)
+	}
+	return result
+}
+
+// AssertProvides checks that each interface has exactly one assignable instance,
+// interfaces are passed as nil pointers, for example, (*Service)(nil).
+// A lazy context builds all instances first.
+func (ctx *Context) AssertProvides(ifaces ...interface{}) error {
+	if err := ctx.initLazy(); err != nil {
+		return err
+	}
+
+	for _, iface := range ifaces {
+		ptr := reflect.TypeOf(iface)
+		if ptr == nil || ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Interface {
+			return fmt.Errorf("di: not an interface pointer, type=%T", iface)
+		}
+		typ := ptr.Elem()
+
+		matches := []string{}
+		for t := range ctx.Instances {
+			if t.AssignableTo(typ) {
+				matches = append(matches, t.String())
+			}
+		}
+		sort.Strings(matches)
+
+		switch len(matches) {
+		case 0:
+			return fmt.Errorf("di: no instance provides interface, type=%v", typ)
+		case 1:
+		default:
+			return fmt.Errorf("di: ambiguous instances provide interface, type=%v, instances=%v",
+				typ, strings.Join(matches, ", "))
+		}
+	}
+	return nil
+}
+
+// WhyProvided returns the names of the providers which depend on a given type,
+// in the construction order. A lazy context builds all instances first, and returns none
+// when a provider fails.
+func (ctx *Context) WhyProvided(typ reflect.Type) []string {
+	names := []string{}
+	if err := ctx.initLazy(); err != nil {
+		return names
+	}
+
+	for _, p := range ctx.InstanceProviders {
+	deps:
+		for _, dep := range p.Deps {
+			depProviders, _, _ := ctx.resolve(p.Module, dep)
+			for _, depProvider := range depProviders {
+				if depProvider.Type == typ {
+					names = append(names, p.Name)
+					break deps
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Provider returns the public provider of a type.
+func (ctx *Context) Provider(typ reflect.Type) (*Provider, bool) {
+	p, ok := ctx.Providers[typ]
+	return p, ok
+}
+
+// DepsOf returns the dependency types of a type provider in the parameter order,
+// or nil when there is no provider.
+func (ctx *Context) DepsOf(typ reflect.Type) []reflect.Type {
+	p, ok := ctx.Providers[typ]
+	if !ok {
+		return nil
+	}
+	return append([]reflect.Type{}, p.Deps...)
+}
+
+// ProvidedTypes returns the types of the public module providers sorted by type names,
+// built-in and resolved types are not included.
+func (ctx *Context) ProvidedTypes() []reflect.Type {
+	types := []reflect.Type{}
+	for typ, p := range ctx.Providers {
+		if p.Module != ctx.builtin {
+			types = append(types, typ)
+		}
+	}
+
+	sortTypes(types)
+	return types
+}
+
+// UnreachableProviders returns the types of the providers which are not reachable from the roots,
+// the Starter and Stopper instances, or the module invokes, in the construction order.
+// These providers are constructed only because the build is eager.
+func (ctx *Context) UnreachableProviders(roots ...reflect.Type) []reflect.Type {
+	providers := []*Provider{}
+	for _, typ := range roots {
+		if p, ok := ctx.Providers[typ]; ok {
+			providers = append(providers, p)
+		}
+	}
+	for i, p := range ctx.InstanceProviders {
+		switch ctx.InstanceSlice[i].(type) {
+		case Starter, Stopper:
+			providers = append(providers, p)
+		}
+	}
+	providers = append(providers, ctx.invokes...)
+	reached := ctx.reachable(providers)
+
+	types := []reflect.Type{}
+	for _, p := range ctx.InstanceProviders {
+		if !reached[p] {
+			types = append(types, p.Type)
+		}
+	}
+	return types
+}
+
+// reachable returns the providers and their transitive dependencies.
+func (ctx *Context) reachable(providers []*Provider) map[*Provider]bool {
+	reached := map[*Provider]bool{}
+
+	var visit func(p *Provider)
+	visit = func(p *Provider) {
+		if reached[p] {
+			return
+		}
+		reached[p] = true
+
+		for _, dep := range p.Deps {
+			depProviders, _, _ := ctx.resolve(p.Module, dep)
+			for _, depProvider := range depProviders {
+				visit(depProvider)
+			}
+		}
+	}
+
+	for _, p := range providers {
+		visit(p)
+	}
+	return reached
+}
+
+func (ctx *Context) initModules(mfuncs []ModuleFunc) error {
+	for _, mfunc := range mfuncs {
+		prevNames := []string{}
+		if _, err := ctx.initModule(mfunc, prevNames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) initModule(mfunc ModuleFunc, prevNames []string) (*Module, error) {
+	name := mfunc.Name()
+	if m, ok := ctx.Modules[name]; ok {
+		return m, nil
+	}
+
+	// Prevent cyclic imports.
+	{
+		path := []string{name}
+		for i := len(prevNames) - 1; i >= 0; i-- {
+			prev := prevNames[i]
+			path = append(path, prev)
+
+			if prev == name {
+				return nil, fmt.Errorf("di: cyclic import %v", strings.Join(path, " -> "))
+			}
+		}
+	}
+	prevNames = append(prevNames, name)
+
+	// Start module initialization.
+	m, err := ctx.newModule(mfunc)
+	if err != nil {
+		return nil, err
+	}
+	ctx.stopHooks = append(ctx.stopHooks, m.StopHooks...)
+	ctx.invokes = append(ctx.invokes, m.Invokes...)
+	ctx.Resolvers = append(ctx.Resolvers, m.Resolvers...)
+
+	// Resolve imported modules.
+	for _, impfunc := range m.Imports {
+		if _, err := ctx.initModule(impfunc, prevNames); err != nil {
+			return nil, err
+		}
+	}
+
+	// Add the initialized module to the context.
+	ctx.Modules[name] = m
+	return m, nil
+}
+
+// newModule creates a module, and recovers from registration panics when the context is panic free.
+func (ctx *Context) newModule(mfunc ModuleFunc) (m *Module, err error) {
+	if ctx.options.PanicFree {
+		defer func() {
+			r := recover()
+			switch r := r.(type) {
+			case nil:
+			case error:
+				err = r
+			default:
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+	}
+	return newModule(mfunc), nil
+}
+
+func (ctx *Context) initProviders() error {
+	// Add providers to the package, prevent duplicates, let overrides supersede the others.
+	modules := []*Module{}
+	for _, m := range ctx.Modules {
+		if !m.override {
+			modules = append(modules, m)
+		}
+	}
+	for _, m := range ctx.Modules {
+		if m.override {
+			modules = append(modules, m)
+		}
+	}
+	for _, m := range modules {
+		for _, p := range m.Providers {
+			if p.Private || p.Group {
+				continue
+			}
+			if p.Qualifier != "" {
+				key := namedKey{typ: p.Type, name: p.Qualifier}
+				if p1, ok := ctx.named[key]; ok {
+					return fmt.Errorf("di: duplicate named provider, type=%v, name=%v, module0=%v, module1=%v",
+						p.Type, p.Qualifier, p.Module.Name, p1.Module.Name)
+				}
+				ctx.named[key] = p
+				continue
+			}
+			if p1, ok := ctx.Providers[p.Type]; ok && !(m.override && !p1.Module.override) {
+				if p.Impl != p.Type || p1.Impl != p1.Type {
+					return fmt.Errorf(
+						"di: duplicate provider, type=%v, module0=%v, impl0=%v, module1=%v, impl1=%v",
+						p.Type, p.Module.Name, p.Impl, p1.Module.Name, p1.Impl)
+				}
+				return fmt.Errorf("di: duplicate provider, type=%v, module0=%v, module1=%v",
+					p.Type, p.Module.Name, p1.Module.Name)
+			}
+
+			ctx.Providers[p.Type] = p
+		}
+	}
+
+	// Collect group providers, order them by orders, then by modules and registration.
+	names := []string{}
+	for name := range ctx.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, p := range ctx.Modules[name].Providers {
+			if p.Group {
+				ctx.groups = append(ctx.groups, p)
+			}
+		}
+	}
+	sort.SliceStable(ctx.groups, func(i, j int) bool {
+		return ctx.groups[i].Order < ctx.groups[j].Order
+	})
+
+	// Check provider dependencies.
+	for _, m := range ctx.Modules {
+		availableDeps := map[reflect.Type]bool{}
+
+		// Add built-in providers.
+		for _, dep := range ctx.builtin.Providers {
+			availableDeps[dep.Type] = true
+		}
+
+		// Add providers from the imported modules.
+		for _, imp := range m.Imports {
+			impModule := ctx.Modules[imp.Name()]
+			for _, dep := range impModule.Providers {
+				if !dep.Private && !dep.Group && dep.Qualifier == "" {
+					availableDeps[dep.Type] = true
+				}
+			}
+		}
+
+		// Add this module providers.
+		for _, p := range m.Providers {
+			if p.Qualifier == "" {
+				availableDeps[p.Type] = true
+			}
+		}
+
+		// Add existing explicit dependencies.
+		for _, dep := range m.Deps {
+			_, ok := ctx.Providers[dep]
+			if ok {
+				availableDeps[dep] = true
+			}
+		}
+
+		// Check provider and invoke dependencies.
+		providers := append(append([]*Provider{}, m.Providers...), m.Invokes...)
+		for _, p := range providers {
+			for _, dep := range p.Deps {
+				if _, ok := optionalElem(dep); ok || dep == moduleNameType || dep == contextType {
+					continue
+				}
+				if p.AfterAll && dep == instancesType {
+					continue
+				}
+				if depProvider, ok := ctx.Providers[dep]; ok && depProvider.AfterAll {
+					return fmt.Errorf(
+						"di: cannot depend on an after-all provider, dep=%v, provider=%v, module=%v",
+						dep, p, m.Name)
+				}
+				if len(ctx.groupProviders(dep)) > 0 || len(ctx.namedProviders(dep)) > 0 {
+					continue
+				}
+				if key, ok := namedDepKey(dep); ok {
+					if ctx.namedProvider(key) == nil {
+						return fmt.Errorf(
+							"di: unresolved named provider dependency, dep=%v, name=%v, provider=%v, module=%v",
+							key.typ, key.name, p, m.Name)
+					}
+					continue
+				}
+				if _, ok := ctx.Providers[dep]; !ok {
+					external, err := ctx.resolveExternal(dep)
+					if err != nil {
+						return err
+					}
+					if external != nil {
+						availableDeps[dep] = true
+					}
+				}
+				if _, ok := availableDeps[dep]; !ok {
+					return fmt.Errorf(
+						"di: unresolved provider dependency, dep=%v, provider=%v, module=%v",
+						dep, p, m.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkAcyclic returns an error with the cycle path when provider dependencies form a cycle.
+func (ctx *Context) checkAcyclic() error {
+	done := map[*Provider]bool{}
+	path := []*Provider{}
+
+	var visit func(p *Provider) error
+	visit = func(p *Provider) error {
+		if done[p] {
+			return nil
+		}
+		for i, p0 := range path {
+			if p0 != p {
+				continue
+			}
+
+			names := []string{}
+			for _, p1 := range path[i:] {
+				names = append(names, p1.Name)
+			}
+			names = append(names, p.Name)
+			return fmt.Errorf("di: dependency cycle %v", strings.Join(names, " -> "))
+		}
+
+		path = append(path, p)
+		for _, dep := range p.Deps {
+			// Unresolved dependencies are reported when initializing instances.
+			depProviders, _, _ := ctx.resolve(p.Module, dep)
+			for _, depProvider := range depProviders {
+				if err := visit(depProvider); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+
+		done[p] = true
+		return nil
+	}
+
+	for _, m := range ctx.Modules {
+		for _, p := range m.Providers {
+			if err := visit(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) initInstances() error {
+	if ctx.options.Parallel {
+		return ctx.initInstancesParallel()
+	}
+
+	for _, typ := range ctx.options.SeedOrder {
+		p, ok := ctx.Providers[typ]
+		if !ok || p.AfterAll || p.Transient {
+			continue
+		}
+		if _, err := ctx.initInstance(p); err != nil {
+			return err
+		}
+	}
+
+	return ctx.initAll()
+}
+
+// initAll initializes the instances which are not built yet, and then the after-all ones.
+func (ctx *Context) initAll() error {
+	for _, m := range ctx.Modules {
+		for _, p := range m.Providers {
+			if p.AfterAll || p.Transient || ctx.superseded(p) {
+				continue
+			}
+			if _, err := ctx.initInstance(p); err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.initAfterAll()
+}
+
+// initLazy initializes all instances of a lazy context, so that the queries over all instances
+// see them.
+func (ctx *Context) initLazy() error {
+	if !ctx.options.Lazy {
+		return nil
+	}
+	return ctx.initAll()
+}
+
+// superseded returns true when a provider is superseded by an override.
+func (ctx *Context) superseded(p *Provider) bool {
+	if p.Private || p.Group || p.Qualifier != "" {
+		return false
+	}
+	return ctx.Providers[p.Type] != p
+}
+
+// initAfterAll initializes the after-all providers ordered by modules and registration.
+func (ctx *Context) initAfterAll() error {
+	names := []string{}
+	for name := range ctx.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, p := range ctx.Modules[name].Providers {
+			if !p.AfterAll || ctx.superseded(p) {
+				continue
+			}
+			if _, err := ctx.initInstance(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) initInstance(p *Provider) (interface{}, error) {
+	instance, ok := ctx.instances[p]
+	if ok {
+		return instance, nil
+	}
+
+	ctx.tracef("resolving %v%v", p.Type, traceDeps(p.Deps))
+	ctx.depth++
+	args := []interface{}{}
+	for _, dep := range p.Deps {
+		arg, err := ctx.initDep(p, dep)
+		if err != nil {
+			ctx.depth--
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+	ctx.depth--
+
+	instance, ok = ctx.reused[p]
+	if !ok {
+		var err error
+		started := time.Now()
+		instance, err = ctx.call(p, args)
+		if err != nil {
+			return nil, err
+		}
+		ctx.addTiming(p, time.Since(started))
+		ctx.tracef("built %v in %v", p.Type, time.Since(started))
+	}
+
+	if p.Transient {
+		return instance, nil
+	}
+	ctx.addInstance(p, instance)
+	return instance, nil
+}
+
+// tracef writes a resolution trace line indented by the resolution depth.
+func (ctx *Context) tracef(format string, args ...interface{}) {
+	if ctx.options.Trace == nil {
+		return
+	}
+	fmt.Fprintf(ctx.options.Trace, strings.Repeat("  ", ctx.depth)+format+"\n", args...)
+}
+
+func traceDeps(deps []reflect.Type) string {
+	if len(deps) == 0 {
+		return ""
+	}
+
+	names := []string{}
+	for _, dep := range deps {
+		names = append(names, dep.String())
+	}
+	return fmt.Sprintf(" (needs %v)", strings.Join(names, ", "))
+}
+
+// initInstancesParallel initializes the instances level by level, the providers of a level depend
+// only on the previous levels and are called concurrently. The first error cancels the build context.
+func (ctx *Context) initInstancesParallel() error {
+	// Compute the longest dependency path length for each provider.
+	levels := map[*Provider]int{}
+	var level func(p *Provider) (int, error)
+	level = func(p *Provider) (int, error) {
+		if l, ok := levels[p]; ok {
+			return l, nil
+		}
+
+		l := 0
+		for _, dep := range p.Deps {
+			depProviders, _, err := ctx.resolve(p.Module, dep)
+			if err != nil {
+				return 0, err
+			}
+			for _, depProvider := range depProviders {
+				depLevel, err := level(depProvider)
+				if err != nil {
+					return 0, err
+				}
+				if depLevel+1 > l {
+					l = depLevel + 1
+				}
+			}
+		}
+
+		levels[p] = l
+		return l, nil
+	}
+
+	// Group the providers by levels.
+	groups := [][]*Provider{}
+	for _, m := range ctx.Modules {
+		for _, p := range m.Providers {
+			if p.AfterAll || p.Transient || ctx.superseded(p) {
+				continue
+			}
+			l, err := level(p)
+			if err != nil {
+				return err
+			}
+			for len(groups) <= l {
+				groups = append(groups, nil)
+			}
+			groups[l] = append(groups[l], p)
+		}
+	}
+
+	// Cancel the in-flight providers on the first error.
+	build := ctx.build
+	if build == nil {
+		build = context.Background()
+	}
+	cancelCtx, cancel := context.WithCancel(build)
+	defer cancel()
+	ctx.build = cancelCtx
+	defer func() { ctx.build = build }()
+
+	mu := sync.Mutex{}
+	var firstErr error
+	var panicked interface{}
+
+	for _, group := range groups {
+		wg := sync.WaitGroup{}
+		for _, p := range group {
+			wg.Add(1)
+			go func(p *Provider) {
+				defer wg.Done()
+				defer func() {
+					if r := recover(); r != nil {
+						mu.Lock()
+						panicked = r
+						mu.Unlock()
+						cancel()
+					}
+				}()
+
+				if err := ctx.initInstanceLocked(&mu, p); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					cancel()
+				}
+			}(p)
+		}
+		wg.Wait()
+
+		switch {
+		case panicked != nil:
+			panic(panicked)
+		case firstErr != nil:
+			return firstErr
+		}
+	}
+	return ctx.initAfterAll()
+}
+
+// initInstanceLocked initializes an instance, whose dependencies are already initialized,
+// and calls its provider without holding the lock.
+func (ctx *Context) initInstanceLocked(mu *sync.Mutex, p *Provider) error {
+	mu.Lock()
+	if _, ok := ctx.instances[p]; ok {
+		mu.Unlock()
+		return nil
+	}
+
+	args := []interface{}{}
+	for _, dep := range p.Deps {
+		arg, err := ctx.initDep(p, dep)
+		if err != nil {
+			mu.Unlock()
+			return err
+		}
+		args = append(args, arg)
+	}
+
+	instance, reused := ctx.reused[p]
+	mu.Unlock()
+
+	var duration time.Duration
+	if !reused {
+		var err error
+		started := time.Now()
+		instance, err = ctx.call(p, args)
+		if err != nil {
+			return err
+		}
+		duration = time.Since(started)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reused {
+		ctx.addTiming(p, duration)
+	}
+	ctx.addInstance(p, instance)
+	return nil
+}
+
+// addTiming records a module provider call duration during builds and reloads,
+// rebuilds after evictions are not timed, the timings may be read concurrently.
+func (ctx *Context) addTiming(p *Provider, duration time.Duration) {
+	if ctx.building && p.Module != ctx.builtin {
+		ctx.timings[p.Type] = duration
+	}
+}
+
+// addInstance adds an initialized instance to this context, the built-in instances are not added
+// to InstanceSlice and InstanceProviders.
+func (ctx *Context) addInstance(p *Provider, instance interface{}) {
+	ctx.instances[p] = instance
+	if !p.Private && p.Qualifier == "" {
+		ctx.Instances[p.Type] = instance
+	}
+	if p.Module == ctx.builtin {
+		return
+	}
+	ctx.InstanceSlice = append(ctx.InstanceSlice, instance)
+	ctx.InstanceProviders = append(ctx.InstanceProviders, p)
+}
+
+// checkWarnings reports the build diagnostics according to the warning policy.
+func (ctx *Context) checkWarnings() error {
+	if unused := ctx.UnreachableProviders(); len(unused) > 0 {
+		if err := ctx.warn(WarningUnusedProviders, fmt.Sprintf("unused providers, types=%v", unused)); err != nil {
+			return err
+		}
+	}
+
+	for i, p := range ctx.InstanceProviders {
+		_, starter := ctx.InstanceSlice[i].(Starter)
+		_, stopper := ctx.InstanceSlice[i].(Stopper)
+		if !starter && !stopper {
+			continue
+		}
+
+		if dep := ctx.transientDep(p, map[*Provider]bool{}); dep != nil {
+			msg := fmt.Sprintf("lifecycle service depends on transient provider, service=%v, dep=%v", p.Type, dep.Type)
+			if err := ctx.warn(WarningTransientDeps, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// transientDep returns the first transient provider which a provider transitively depends on.
+func (ctx *Context) transientDep(p *Provider, visited map[*Provider]bool) *Provider {
+	for _, dep := range p.Deps {
+		depProviders, _, _ := ctx.resolve(p.Module, dep)
+		for _, depProvider := range depProviders {
+			if depProvider.Transient {
+				return depProvider
+			}
+			if visited[depProvider] {
+				continue
+			}
+			visited[depProvider] = true
+			if t := ctx.transientDep(depProvider, visited); t != nil {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// warn logs a diagnostic, or returns it as an error when its category policy is PolicyError.
+func (ctx *Context) warn(category string, msg string) error {
+	switch ctx.options.WarningPolicy[category] {
+	case PolicyIgnore:
+		return nil
+	case PolicyError:
+		return fmt.Errorf("di: %v", msg)
+	}
+
+	if ctx.options.Logger != nil {
+		ctx.options.Logger.Println("Warning:", msg)
+	}
+	return nil
+}
+
+// initInvokes calls the module invokes with injected dependencies, except the skipped ones.
+func (ctx *Context) initInvokes(skip func(p *Provider) bool) error {
+	for _, p := range ctx.invokes {
+		if skip != nil && skip(p) {
+			continue
+		}
+
+		args := []interface{}{}
+		for _, dep := range p.Deps {
+			arg, err := ctx.initDep(p, dep)
+			if err != nil {
+				return err
+			}
+
+			args = append(args, arg)
+		}
+
+		if _, err := ctx.call(p, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call calls a provider function and aborts waiting for it when the build context is done.
+func (ctx *Context) call(p *Provider, args []interface{}) (interface{}, error) {
+	if ctx.build == nil || ctx.build.Done() == nil {
+		return p.Func(args)
+	}
+	if err := ctx.build.Err(); err != nil {
+		return nil, fmt.Errorf("di: build aborted, provider=%v: %w", p, err)
+	}
+
+	type result struct {
+		instance interface{}
+		err      error
+		panic    interface{}
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- result{panic: r}
+			}
+		}()
+
+		instance, err := p.Func(args)
+		ch <- result{instance: instance, err: err}
+	}()
+
+	select {
+	case <-ctx.build.Done():
+		return nil, fmt.Errorf("di: build aborted, provider=%v: %w", p, ctx.build.Err())
+	case r := <-ch:
+		if r.panic != nil {
+			panic(r.panic)
+		}
+		return r.instance, r.err
+	}
+}
+
+// initDep returns a provider dependency instance, wraps optional dependencies,
+// and returns an error when a required dependency is nil.
+func (ctx *Context) initDep(p *Provider, dep reflect.Type) (interface{}, error) {
+	switch dep {
+	case moduleNameType:
+		return ModuleName(p.Module.Name), nil
+	case contextType:
+		if ctx.build == nil {
+			return context.Background(), nil
+		}
+		return ctx.build, nil
+	case instancesType:
+		if p.AfterAll {
+			return append([]interface{}{}, ctx.InstanceSlice...), nil
+		}
+	}
+
+	depProviders, group, err := ctx.resolve(p.Module, dep)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := []interface{}{}
+	for _, depProvider := range depProviders {
+		instance, err := ctx.initInstance(depProvider)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	var instance interface{}
+	switch {
+	case group && namedDep(dep):
+		typ := dep
+		if elem, ok := optionalElem(dep); ok {
+			typ = elem
+		}
+		instance = namedSlice(typ, depProviders, instances)
+
+	case group && groupMap(dep):
+		typ := dep
+		if elem, ok := optionalElem(dep); ok {
+			typ = elem
+		}
+
+		instance, err = keyedGroup(typ, instances)
+		if err != nil {
+			return nil, err
+		}
+
+	case group:
+		typ := dep
+		if elem, ok := optionalElem(dep); ok {
+			typ = elem
+		}
+
+		slice := reflect.MakeSlice(typ, 0, len(instances))
+		for _, instance := range instances {
+			slice = reflect.Append(slice, reflect.ValueOf(instance))
+		}
+		instance = slice.Interface()
+
+	case len(instances) > 0:
+		instance = instances[0]
+	}
+
+	if opt, ok := reflect.Zero(dep).Interface().(optional); ok {
+		if isNil(instance) {
+			return opt, nil
+		}
+		return opt.with(instance), nil
+	}
+
+	if isNil(instance) {
+		return nil, fmt.Errorf("di: nil instance, type=%v, provider=%v", dep, p)
+	}
+	return instance, nil
+}
+
+// resolve returns the providers for a dependency of a module: one provider for a regular
+// dependency, the group providers for a group dependency, and none for an absent optional one.
+// Private module providers take precedence over context providers, and both take precedence
+// over groups.
+func (ctx *Context) resolve(m *Module, typ reflect.Type) (providers []*Provider, group bool, err error) {
+	elem, optional := optionalElem(typ)
+	if optional {
+		typ = elem
+	}
+	if typ == moduleNameType || typ == contextType {
+		return nil, false, nil
+	}
+	if key, ok := namedDepKey(typ); ok {
+		if p := ctx.namedProvider(key); p != nil {
+			return []*Provider{p}, false, nil
+		}
+		if optional {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("di: no named provider, type=%v, name=%v", key.typ, key.name)
+	}
+
+	if p := m.privateProvider(typ); p != nil {
+		return []*Provider{p}, false, nil
+	}
+	if p, ok := ctx.Providers[typ]; ok {
+		return []*Provider{p}, false, nil
+	}
+	if providers := ctx.namedProviders(typ); len(providers) > 0 {
+		return providers, true, nil
+	}
+	if providers := ctx.groupProviders(typ); len(providers) > 0 {
+		return providers, true, nil
+	}
+	if p, err := ctx.resolveExternal(typ); err != nil || p != nil {
+		return []*Provider{p}, false, err
+	}
+	if optional {
+		return nil, false, nil
+	}
+	return nil, false, fmt.Errorf("di: no provider, type=%v", typ)
+}
+
+// resolveExternal asks the resolvers, and then the parents of a scope, for an instance of a type
+// without a provider, and adds a built-in provider for it. It returns nil when none resolves the type.
+func (ctx *Context) resolveExternal(typ reflect.Type) (*Provider, error) {
+	for _, r := range ctx.Resolvers {
+		instance, ok := r.Resolve(typ)
+		if !ok {
+			continue
+		}
+		if instance != nil && !reflect.TypeOf(instance).AssignableTo(typ) {
+			return nil, fmt.Errorf("di: resolver returned invalid instance, type=%v, instance=%T, resolver=%T",
+				typ, instance, r)
+		}
+
+		p := &Provider{
+			Module: ctx.builtin,
+			Name:   fmt.Sprintf("%T", r),
+			Type:   typ,
+			Impl:   typ,
+			Deps:   []reflect.Type{},
+			Func: func([]interface{}) (interface{}, error) {
+				return instance, nil
+			},
+			IsInstance: true,
+		}
+		ctx.builtin.Providers = append(ctx.builtin.Providers, p)
+		ctx.Providers[typ] = p
+		return p, nil
+	}
+	return ctx.resolveParent(typ), nil
+}
+
+// groupProviders returns the group providers assignable to a slice or map type element,
+// in a scope the parent group providers precede its own ones.
+func (ctx *Context) groupProviders(typ reflect.Type) []*Provider {
+	if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Map {
+		return nil
+	}
+
+	providers := []*Provider{}
+	for _, c := range ctx.scopeChain() {
+		for _, p := range c.groups {
+			if !p.Type.AssignableTo(typ.Elem()) {
+				continue
+			}
+			if c != ctx {
+				p = ctx.proxyProvider(c, p)
+			}
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// groupMap returns true when a group dependency is a map, optionally wrapped.
+func groupMap(dep reflect.Type) bool {
+	if elem, ok := optionalElem(dep); ok {
+		dep = elem
+	}
+	return dep.Kind() == reflect.Map
+}
+
+// keyedGroup returns a map of group instances keyed by their Key methods,
+// for example, map[Kind]Handler from handlers with Key() Kind methods.
+func keyedGroup(typ reflect.Type, instances []interface{}) (interface{}, error) {
+	result := reflect.MakeMapWithSize(typ, len(instances))
+	for _, instance := range instances {
+		v := reflect.ValueOf(instance)
+		method := v.MethodByName("Key")
+		if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 ||
+			!method.Type().Out(0).AssignableTo(typ.Key()) {
+			return nil, fmt.Errorf("di: group instance has no Key() %v method, type=%T", typ.Key(), instance)
+		}
+
+		key := method.Call(nil)[0]
+		if result.MapIndex(key).IsValid() {
+			return nil, fmt.Errorf("di: duplicate group key, type=%v, key=%v", typ, key)
+		}
+		result.SetMapIndex(key, v)
+	}
+	return result.Interface(), nil
+}
+
+// lookup returns a provider in this context which corresponds to a provider from another context.
+func (ctx *Context) lookup(p *Provider) *Provider {
+	if p.Qualifier != "" && !p.Private && !p.Group {
+		return ctx.named[namedKey{typ: p.Type, name: p.Qualifier}]
+	}
+	if !p.Private && !p.Group {
+		return ctx.Providers[p.Type]
+	}
+
+	m, ok := ctx.Modules[p.Module.Name]
+	if !ok {
+		return nil
+	}
+	if p.Private {
+		return m.privateProvider(p.Type)
+	}
+
+	// Group providers are matched by their positions in modules.
+	for i, p1 := range p.Module.Providers {
+		if p1 != p {
+			continue
+		}
+		if i < len(m.Providers) && m.Providers[i].Group && m.Providers[i].Type == p.Type {
+			return m.Providers[i]
+		}
+	}
+	return nil
+}
+
+// unchangedProviders maps the providers of this context to the providers of a previous context
+// when their instances can be reused, i.e. with the same names and unchanged dependencies.
+func (ctx *Context) unchangedProviders(prev *Context) map[*Provider]*Provider {
+	unchanged := map[*Provider]*Provider{}
+	checked := map[*Provider]bool{}
+
+	var check func(p *Provider) bool
+	check = func(p *Provider) bool {
+		if result, ok := checked[p]; ok {
+			return result
+		}
+		checked[p] = false
+
+		if p.Module == ctx.builtin {
+			return false
+		}
+		p0 := prev.lookup(p)
+		if p0 == nil || p0.Name != p.Name || len(p0.Deps) != len(p.Deps) {
+			return false
+		}
+		instance, ok := prev.instances[p0]
+		if !ok {
+			return false
+		}
+		if p.IsInstance && !sameInstance(p, instance) {
+			return false
+		}
+		for i, dep := range p.Deps {
+			if dep != p0.Deps[i] {
+				return false
+			}
+
+			depProviders, _, err := ctx.resolve(p.Module, dep)
+			if err != nil {
+				return false
+			}
+			depProviders0, _, err := prev.resolve(p0.Module, dep)
+			if err != nil || len(depProviders0) != len(depProviders) {
+				return false
+			}
+			for j, depProvider := range depProviders {
+				if !check(depProvider) || depProviders0[j] != unchanged[depProvider] {
+					return false
+				}
+			}
+		}
+
+		checked[p] = true
+		unchanged[p] = p0
+		return true
+	}
+
+	for _, m := range ctx.Modules {
+		for _, p := range m.Providers {
+			check(p)
+		}
+	}
+	return unchanged
+}
+
+// unchangedInvoke returns true when a previous context has called the same invoke function
+// with the same dependency instances, a reload does not call it again.
+func (ctx *Context) unchangedInvoke(prev *Context, p *Provider, unchanged map[*Provider]*Provider) bool {
+	called := false
+	for _, p0 := range prev.invokes {
+		if p0.Name == p.Name {
+			called = true
+		}
+	}
+	if !called {
+		return false
+	}
+
+	for _, dep := range p.Deps {
+		depProviders, _, err := ctx.resolve(p.Module, dep)
+		if err != nil {
+			return false
+		}
+		for _, depProvider := range depProviders {
+			if _, ok := unchanged[depProvider]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameInstance returns true when an instance provider provides a given instance.
+func sameInstance(p *Provider, instance interface{}) bool {
+	if !p.Impl.Comparable() {
+		return false
+	}
+	v, _ := p.Func(nil)
+	return v == instance
 }
 
 func getFuncName(fval reflect.Value) string {
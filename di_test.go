@@ -1,8 +1,14 @@
 package di
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,6 +53,30 @@ func Test_NewContext__should_create_and_initialize_context_and_instances(t *test
 	assert.Equal(t, true, service.Bool)
 }
 
+func testImportString(m *Module) { m.AddInstance("hello") }
+func testImportInt(m *Module)    { m.AddInstance(123) }
+func testImportBool(m *Module)   { m.AddInstance(true) }
+
+func Test_Module_ImportAll__should_import_all_modules(t *testing.T) {
+	type Service struct {
+		String string
+		Int    int
+		Bool   bool
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.ImportAll(testImportString, testImportInt, testImportBool)
+		m.Add(func(s string, i int, b bool) *Service { return &Service{s, i, b} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *Service
+	ctx.MustGet(&service)
+	assert.Equal(t, &Service{"hello", 123, true}, service)
+}
+
 func testCyclicImport0(m *Module) { m.Import(testCyclicImport1) }
 func testCyclicImport1(m *Module) { m.Import(testCyclicImport0) }
 
@@ -55,6 +85,260 @@ func Test_NewContext__should_return_error_on_cyclic_module_imports(t *testing.T)
 	assert.Contains(t, err.Error(), "cyclic import")
 }
 
+type testCycleA struct{}
+type testCycleB struct{}
+
+func Test_CheckAcyclic__should_return_nil_for_dag(t *testing.T) {
+	called := false
+	err := CheckAcyclic(func(m *Module) {
+		m.Add(func() *testCycleA { called = true; return &testCycleA{} })
+		m.Add(func(*testCycleA) *testCycleB { return &testCycleB{} })
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, called)
+}
+
+func Test_CheckAcyclic__should_return_constructor_cycle_path(t *testing.T) {
+	newA := func(*testCycleB) *testCycleA { return &testCycleA{} }
+	newB := func(*testCycleA) *testCycleB { return &testCycleB{} }
+	err := CheckAcyclic(func(m *Module) {
+		m.Add(newA)
+		m.Add(newB)
+	})
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "di: dependency cycle")
+		assert.Contains(t, err.Error(), " -> ")
+	}
+
+	_, err = NewContext(func(m *Module) {
+		m.Add(newA)
+		m.Add(newB)
+	})
+	assert.Contains(t, err.Error(), "di: dependency cycle")
+}
+
+func Test_NewContextWithOptions__should_return_registration_error_when_panic_free(t *testing.T) {
+	_, err := NewContextWithOptions(Options{PanicFree: true}, func(m *Module) {
+		m.Add(123)
+	})
+
+	assert.EqualError(t, err, "di: provider must be a function: int")
+}
+
+func Test_Module_AddInstance__should_panic_on_nil_instance(t *testing.T) {
+	assert.PanicsWithValue(t, "di: cannot add nil instance", func() {
+		NewContext(func(m *Module) { m.AddInstance(nil) })
+	})
+}
+
+func testNoOutputProvider() {}
+
+func Test_Module_Add__should_panic_on_provider_without_outputs(t *testing.T) {
+	assert.PanicsWithValue(t,
+		"di: provider must return at least an instance: github.com/ivankorobkov/di.testNoOutputProvider",
+		func() {
+			NewContext(func(m *Module) { m.Add(testNoOutputProvider) })
+		})
+}
+
+func Test_NewContextWithOptions__should_construct_instances_in_seed_order(t *testing.T) {
+	seed := []reflect.Type{reflect.TypeOf(int64(0)), reflect.TypeOf(int32(0))}
+	ctx, err := NewContextWithOptions(Options{SeedOrder: seed}, func(m *Module) {
+		m.AddInstance("a")
+		m.Add(func(s string) int32 { return 1 })
+		m.AddInstance(int64(2))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []interface{}{int64(2), "a", int32(1)}, ctx.InstanceSlice)
+}
+
+func Test_NewContextWithOptions__should_trace_resolution_in_dependency_order(t *testing.T) {
+	type DB struct{}
+	type Server struct{}
+
+	trace := &bytes.Buffer{}
+	_, err := NewContextWithOptions(Options{Trace: trace}, func(m *Module) {
+		m.Add(func(*DB, string) *Server { return &Server{} })
+		m.Add(func() *DB { return &DB{} })
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(trace.String(), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "resolving *di.Server") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		t.Fatal(trace.String())
+	}
+
+	assert.Equal(t, "resolving *di.Server (needs *di.DB, string)", lines[start])
+	assert.Equal(t, "  resolving *di.DB", lines[start+1])
+	assert.True(t, strings.HasPrefix(lines[start+2], "  built *di.DB in "))
+	assert.Equal(t, "  resolving string", lines[start+3])
+	assert.True(t, strings.HasPrefix(lines[start+4], "  built string in "))
+	assert.True(t, strings.HasPrefix(lines[start+5], "built *di.Server in "))
+}
+
+func Test_NewContextWithOptions__should_build_instances_on_demand_when_lazy(t *testing.T) {
+	type DB struct{}
+	type Server struct{ DB *DB }
+	type Worker struct{}
+
+	built := map[string]int{}
+	ctx, err := NewContextWithOptions(Options{Lazy: true}, func(m *Module) {
+		m.Add(func() *DB { built["db"]++; return &DB{} })
+		m.Add(func(db *DB) *Server { built["server"]++; return &Server{DB: db} })
+		m.Add(func() *Worker { built["worker"]++; return &Worker{} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, built)
+
+	server := MustGet[*Server](ctx)
+	assert.Same(t, server, MustGet[*Server](ctx))
+	assert.Equal(t, map[string]int{"db": 1, "server": 1}, built)
+
+	s := struct{ DB *DB }{}
+	assert.NoError(t, ctx.Inject(&s))
+	assert.Same(t, server.DB, s.DB)
+	assert.Equal(t, map[string]int{"db": 1, "server": 1}, built)
+}
+
+func Test_NewContextWithOptions__should_supersede_providers_with_overrides(t *testing.T) {
+	type Client struct{ Addr string }
+	type Service struct{ Client *Client }
+
+	realCalled := false
+	clientModule := func(m *Module) {
+		m.Add(func() *Client { realCalled = true; return &Client{Addr: "prod:443"} })
+	}
+	serviceModule := func(m *Module) {
+		m.Import(clientModule)
+		m.Add(func(c *Client) *Service { return &Service{Client: c} })
+	}
+	fakeModule := func(m *Module) {
+		m.AddInstance(&Client{Addr: "fake"})
+	}
+
+	ctx, err := NewContextWithOptions(Options{Overrides: []ModuleFunc{fakeModule}}, serviceModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := MustGet[*Service](ctx)
+	assert.Equal(t, "fake", service.Client.Addr)
+	assert.False(t, realCalled)
+
+	_, err = NewContext(serviceModule, fakeModule)
+	assert.ErrorContains(t, err, "di: duplicate provider, type=*di.Client")
+}
+
+func Test_Context_MustGet__should_panic_with_lazy_provider_error(t *testing.T) {
+	type DB struct{}
+
+	ctx, err := NewContextWithOptions(Options{Lazy: true}, func(m *Module) {
+		m.Add(func() (*DB, error) { return nil, errors.New("connection refused") })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var db *DB
+	assert.False(t, ctx.Get(&db))
+	assert.PanicsWithValue(t, "di: no instance, type=**di.DB, err=connection refused", func() { ctx.MustGet(&db) })
+	assert.PanicsWithValue(t, "di: no instance, type=*di.DB, err=connection refused", func() { MustGet[*DB](ctx) })
+}
+
+func Test_NewContextWithOptions__should_build_lazy_instances_for_all_queries(t *testing.T) {
+	type Service struct {
+		count int `di:"inject"`
+	}
+
+	newLazy := func() *Context {
+		ctx, err := NewContextWithOptions(Options{Lazy: true}, func(m *Module) {
+			m.AddInstance(123)
+			m.Add(func(n int) *testMemoryStore { return &testMemoryStore{} })
+			m.AddNamedInstance("replica", "replica:5432")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ctx
+	}
+
+	var addr string
+	assert.True(t, newLazy().GetNamed("replica", &addr))
+	assert.Equal(t, "replica:5432", addr)
+
+	service := &Service{}
+	assert.NoError(t, newLazy().InjectUnsafe(service))
+	assert.Equal(t, 123, service.count)
+
+	assert.NoError(t, newLazy().AssertProvides((*testStore)(nil)))
+
+	var stores []testStore
+	assert.True(t, newLazy().GetAll(&stores))
+	assert.Len(t, stores, 1)
+
+	var store testStore
+	assert.True(t, newLazy().GetAs(&store, reflect.TypeOf(&testMemoryStore{})))
+
+	assert.Len(t, newLazy().WhyProvided(reflect.TypeOf(0)), 1)
+}
+
+func Test_NewContextWithOptions__should_build_lazy_after_all_provider_with_all_instances(t *testing.T) {
+	type Monitor struct{ Instances []interface{} }
+
+	module := func(m *Module) {
+		m.AddAfterAll(func(instances []interface{}) *Monitor { return &Monitor{Instances: instances} })
+		m.AddInstance("hello")
+		m.Add(func(s string) *testMemoryStore { return &testMemoryStore{} })
+	}
+
+	eager, err := NewContext(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := NewContextWithOptions(Options{Lazy: true}, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monitor := MustGet[*Monitor](lazy)
+	assert.Contains(t, monitor.Instances, "hello")
+	assert.Len(t, monitor.Instances, len(MustGet[*Monitor](eager).Instances))
+}
+
+func Test_NewContextWithOptions__should_apply_warning_policy_to_unused_providers(t *testing.T) {
+	module := func(m *Module) {
+		m.AddInstance("unused")
+		m.AddInstance(&testAppService{})
+	}
+
+	_, err := NewContextWithOptions(Options{
+		WarningPolicy: map[string]WarningPolicy{WarningUnusedProviders: PolicyError},
+	}, module)
+	assert.EqualError(t, err, "di: unused providers, types=[string]")
+
+	logger := &testLogger{}
+	_, err = NewContextWithOptions(Options{Logger: logger}, module)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Warning: unused providers, types=[string]"}, logger.lines)
+}
+
 func Test_NewContext__should_return_error_on_duplicate_providers(t *testing.T) {
 	_, err := NewContext(func(m *Module) {
 		m.AddInstance("hello")
@@ -120,6 +404,84 @@ func Test_Context_Get__should_return_false_when_instance_is_not_found(t *testing
 	assert.False(t, ok)
 }
 
+func Test_Get__should_get_typed_instance_from_context(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, ok := Get[string](ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "hello", s)
+
+	_, ok = Get[int](ctx)
+	assert.False(t, ok)
+
+	assert.Equal(t, "hello", MustGet[string](ctx))
+	assert.PanicsWithValue(t, "di: no instance, type=int", func() { MustGet[int](ctx) })
+}
+
+type testRepo[T any] struct {
+	Items []T
+}
+
+type testUser struct{ Name string }
+
+type testOrder struct{ ID int }
+
+func Test_NewContext__should_resolve_instantiated_generic_types_independently(t *testing.T) {
+	type Service struct {
+		Users  *testRepo[testUser]
+		Orders *testRepo[testOrder]
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(func() *testRepo[testUser] {
+			return &testRepo[testUser]{Items: []testUser{{Name: "alice"}}}
+		})
+		m.Add(func() *testRepo[testOrder] {
+			return &testRepo[testOrder]{Items: []testOrder{{ID: 1}}}
+		})
+		m.Add(func(users *testRepo[testUser], orders *testRepo[testOrder]) *Service {
+			return &Service{Users: users, Orders: orders}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *Service
+	ctx.MustGet(&service)
+	assert.Equal(t, []testUser{{Name: "alice"}}, service.Users.Items)
+	assert.Equal(t, []testOrder{{ID: 1}}, service.Orders.Items)
+
+	injected := &Service{}
+	ctx.Inject(injected)
+	assert.Same(t, service.Users, injected.Users)
+	assert.Same(t, service.Orders, injected.Orders)
+}
+
+func Test_Context_AllInstances__should_return_copy_of_instances(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instances := ctx.AllInstances()
+	assert.Equal(t, "hello", instances[reflect.TypeOf("")])
+
+	instances[reflect.TypeOf("")] = "changed"
+	instances[reflect.TypeOf(0)] = 123
+
+	assert.Equal(t, "hello", ctx.Instances[reflect.TypeOf("")])
+	_, ok := ctx.Instances[reflect.TypeOf(0)]
+	assert.False(t, ok)
+}
+
 func Test_Context_Inject__should_inject_dependencies_into_struct_fields(t *testing.T) {
 	ctx, err := NewContext(func(m *Module) {
 		m.AddInstance("hello")
@@ -141,3 +503,1136 @@ func Test_Context_Inject__should_inject_dependencies_into_struct_fields(t *testi
 	assert.Equal(t, 123, s.Int)
 	assert.Equal(t, true, s.Bool)
 }
+
+func Test_Context_Inject__should_honor_struct_tags(t *testing.T) {
+	type Service struct {
+		String  string `di:"required"`
+		Int     int    `di:"-"`
+		Bool    bool   `di:"optional"`
+		Missing []byte `di:"required"`
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.AddInstance(123)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{}
+	err = ctx.Inject(s)
+
+	assert.EqualError(t, err, "di: unfulfilled required fields, type=di.Service, fields=Missing")
+	assert.Equal(t, "hello", s.String)
+	assert.Equal(t, 0, s.Int)
+	assert.False(t, s.Bool)
+}
+
+func Test_NewContext__should_register_func_typed_instance_explicitly(t *testing.T) {
+	called := false
+	callback := func(m *Module) { called = true }
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(callback)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result func(*Module)
+	ctx.MustGet(&result)
+	assert.NotNil(t, result)
+	assert.False(t, called)
+
+	result(nil)
+	assert.True(t, called)
+}
+
+func Test_Context_GetByType__should_get_instance_by_reflect_type(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, ok := ctx.GetByType(reflect.TypeOf(""))
+	assert.True(t, ok)
+	assert.Equal(t, "hello", instance)
+
+	_, ok = ctx.GetByType(reflect.TypeOf(0))
+	assert.False(t, ok)
+}
+
+type testProvidesIface interface {
+	Provide() string
+}
+
+type testProvidesA struct{}
+
+func (testProvidesA) Provide() string { return "a" }
+
+type testProvidesB struct{}
+
+func (testProvidesB) Provide() string { return "b" }
+
+func Test_Context_AssertProvides__should_pass_when_interface_has_one_instance(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(testProvidesA{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctx.AssertProvides((*testProvidesIface)(nil))
+	assert.NoError(t, err)
+}
+
+func Test_Context_AssertProvides__should_return_error_when_interface_is_missing(t *testing.T) {
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctx.AssertProvides((*testProvidesIface)(nil))
+	assert.Contains(t, err.Error(), "no instance provides interface")
+}
+
+func Test_Context_AssertProvides__should_return_error_when_interface_is_ambiguous(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(testProvidesA{})
+		m.AddInstance(testProvidesB{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctx.AssertProvides((*testProvidesIface)(nil))
+	assert.Contains(t, err.Error(), "ambiguous instances provide interface")
+	assert.Contains(t, err.Error(), "di.testProvidesA, di.testProvidesB")
+}
+
+func Test_Provider_IsInstance__should_distinguish_instance_and_function_providers(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.Add(func() int { return 1 })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, ctx.Providers[reflect.TypeOf("")].IsInstance)
+	assert.False(t, ctx.Providers[reflect.TypeOf(0)].IsInstance)
+}
+
+func Test_Context_Clone__should_isolate_instances(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := ctx.Clone()
+	clone.Instances[reflect.TypeOf("")] = "world"
+	clone.Instances[reflect.TypeOf(0)] = 123
+
+	s := ""
+	ctx.MustGet(&s)
+	assert.Equal(t, "hello", s)
+	assert.False(t, ctx.Get(new(int)))
+
+	clone.MustGet(&s)
+	assert.Equal(t, "world", s)
+}
+
+type testUserID int
+
+func Test_NewContext__should_keep_defined_types_distinct_from_underlying_types(t *testing.T) {
+	type Service struct {
+		ID    testUserID
+		Count int
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(testUserID(1))
+		m.AddInstance(2)
+		m.Add(func(id testUserID, count int) *Service {
+			return &Service{ID: id, Count: count}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *Service
+	ctx.MustGet(&service)
+	assert.Equal(t, testUserID(1), service.ID)
+	assert.Equal(t, 2, service.Count)
+
+	s := struct {
+		ID    testUserID
+		Count int
+	}{}
+	ctx.Inject(&s)
+	assert.Equal(t, testUserID(1), s.ID)
+	assert.Equal(t, 2, s.Count)
+}
+
+func Test_Context_Inject__should_inject_interface_instance_into_pointer_to_interface_field(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(func() io.Writer { return buf })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := struct {
+		Writer *io.Writer
+	}{}
+	ctx.Inject(&s)
+
+	if assert.NotNil(t, s.Writer) {
+		assert.Same(t, buf, *s.Writer)
+	}
+}
+
+type testStore interface {
+	Load() string
+}
+
+type testMemoryStore struct{}
+
+func (testMemoryStore) Load() string { return "memory" }
+
+func Test_NewContext__should_register_interface_returning_constructor_under_interface(t *testing.T) {
+	type Service struct {
+		Store testStore
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(func() testStore { return testMemoryStore{} })
+		m.Add(func(store testStore) *Service { return &Service{Store: store} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *Service
+	ctx.MustGet(&service)
+	assert.Equal(t, "memory", service.Store.Load())
+	assert.False(t, ctx.Get(new(testMemoryStore)))
+}
+
+func Test_NewContext__should_return_error_on_duplicate_interface_providers(t *testing.T) {
+	_, err := NewContext(func(m *Module) {
+		m.Add(func() testStore { return testMemoryStore{} })
+	}, func(m *Module) {
+		m.Add(func() testStore { return testMemoryStore{} })
+	})
+
+	assert.Contains(t, err.Error(), "duplicate provider, type=di.testStore")
+}
+
+type testRepository struct {
+	Name string
+}
+
+func Test_Module_AddPrivate__should_allow_same_type_providers_in_sibling_modules(t *testing.T) {
+	type Users struct{ Repo *testRepository }
+	type Orders struct{ Repo *testRepository }
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddPrivate(func() *testRepository { return &testRepository{Name: "users"} })
+		m.Add(func(repo *testRepository) *Users { return &Users{Repo: repo} })
+	}, func(m *Module) {
+		m.AddPrivate(func() *testRepository { return &testRepository{Name: "orders"} })
+		m.Add(func(repo *testRepository) *Orders { return &Orders{Repo: repo} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users *Users
+	var orders *Orders
+	ctx.MustGet(&users)
+	ctx.MustGet(&orders)
+
+	assert.Equal(t, "users", users.Repo.Name)
+	assert.Equal(t, "orders", orders.Repo.Name)
+	assert.False(t, ctx.Get(new(*testRepository)))
+}
+
+func Test_Module_AddPrivate__should_hide_private_providers_from_importing_modules(t *testing.T) {
+	_, err := NewContext(func(m *Module) {
+		m.Import(testPrivateModule)
+		m.Add(func(repo *testRepository) int { return 0 })
+	})
+
+	assert.Contains(t, err.Error(), "unresolved provider dependency")
+}
+
+func testPrivateModule(m *Module) {
+	m.AddPrivate(func() *testRepository { return &testRepository{} })
+}
+
+type testBackend interface {
+	Address() string
+}
+
+type testBackendA struct{}
+
+func (testBackendA) Address() string { return "a" }
+
+type testBackendB struct{}
+
+func (testBackendB) Address() string { return "b" }
+
+func Test_Context_Inject__should_fill_array_with_exact_number_of_instances(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(testBackendA{})
+		m.Add(func(a testBackendA) testBackendB { return testBackendB{} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := struct {
+		Backends [2]testBackend
+		Missing  [3]testBackend
+	}{}
+	ctx.Inject(&s)
+
+	assert.Equal(t, "a", s.Backends[0].Address())
+	assert.Equal(t, "b", s.Backends[1].Address())
+	assert.Equal(t, [3]testBackend{}, s.Missing)
+}
+
+type testReplaceable struct {
+	name    string
+	stopped bool
+}
+
+func (s *testReplaceable) Stop() error {
+	s.stopped = true
+	return nil
+}
+
+func Test_Context_Replace__should_replace_instance_and_stop_old_one(t *testing.T) {
+	old := &testReplaceable{name: "old"}
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replacement := &testReplaceable{name: "new"}
+	if err := ctx.Replace(replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	var instance *testReplaceable
+	ctx.MustGet(&instance)
+	assert.Same(t, replacement, instance)
+	assert.Contains(t, ctx.InstanceSlice, replacement)
+	assert.True(t, old.stopped)
+
+	err = ctx.Replace("unknown")
+	assert.EqualError(t, err, "di: no provider, type=string")
+}
+
+type testHeavyCache struct {
+	generation int
+}
+
+func Test_Context_Evict__should_rebuild_releasable_instance_on_next_get(t *testing.T) {
+	generation := 0
+	ctx, err := NewContext(func(m *Module) {
+		m.AddReleasable(func() *testHeavyCache {
+			generation++
+			return &testHeavyCache{generation: generation}
+		})
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var old *testHeavyCache
+	ctx.MustGet(&old)
+	if err := ctx.Evict(reflect.TypeOf(old)); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, ctx.InstanceSlice, old)
+
+	var cache *testHeavyCache
+	ctx.MustGet(&cache)
+	assert.Equal(t, 2, cache.generation)
+	assert.Contains(t, ctx.InstanceSlice, cache)
+
+	err = ctx.Evict(reflect.TypeOf(""))
+	assert.EqualError(t, err, "di: provider is not releasable, type=string")
+}
+
+func Test_Context_Evict__should_stop_evicted_instance(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddReleasable(func() *testAppService { return &testAppService{} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := MustGet[*testAppService](ctx)
+	if err := ctx.Evict(reflect.TypeOf(old)); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, old.stops)
+	assert.Equal(t, 0, MustGet[*testAppService](ctx).stops)
+}
+
+func Test_Context_InjectUnsafe__should_inject_into_unexported_tagged_fields(t *testing.T) {
+	type Service struct {
+		name  string `di:"inject"`
+		count int
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.AddInstance(123)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &Service{}
+	if err := ctx.InjectUnsafe(service); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hello", service.name)
+	assert.Equal(t, 0, service.count)
+}
+
+func Test_Context_InjectAll__should_inject_into_multiple_structs(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.AddInstance(123)
+		m.AddInstance(true)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := struct{ String string }{}
+	b := struct{ Int int }{}
+	c := struct{ Bool bool }{}
+	err = ctx.InjectAll(&a, &b, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hello", a.String)
+	assert.Equal(t, 123, b.Int)
+	assert.True(t, c.Bool)
+}
+
+func Test_Context_InjectAll__should_return_error_listing_invalid_targets(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := struct{ String string }{}
+	str := ""
+	err = ctx.InjectAll(&a, a, &str)
+
+	assert.EqualError(t, err, "di: not struct pointers, types=struct { String string }, *string")
+	assert.Equal(t, "hello", a.String)
+}
+
+type testSearchClient struct{}
+
+func newTestSearchClient() *testSearchClient { return &testSearchClient{} }
+
+func newTestSearchIndexer(client *testSearchClient) int32 { return 0 }
+
+func newTestSearchQuerier(client Optional[*testSearchClient]) int64 { return 0 }
+
+func Test_Context_WhyProvided__should_return_providers_which_depend_on_type(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(newTestSearchClient)
+		m.Add(newTestSearchIndexer)
+		m.Add(newTestSearchQuerier)
+		m.AddInstance("unrelated")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := ctx.WhyProvided(reflect.TypeOf(&testSearchClient{}))
+	assert.ElementsMatch(t, []string{
+		"github.com/ivankorobkov/di.newTestSearchIndexer",
+		"github.com/ivankorobkov/di.newTestSearchQuerier",
+	}, names)
+	assert.Empty(t, ctx.WhyProvided(reflect.TypeOf(int32(0))))
+}
+
+func Test_Context_UnreachableProviders__should_return_providers_unreachable_from_roots(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(newTestSearchClient)
+		m.Add(newTestSearchIndexer)
+		m.AddInstance(&testAppService{})
+		m.AddInstance("unrelated")
+		m.AddInstance(true)
+		m.Invoke(func(b bool) {})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := ctx.UnreachableProviders(reflect.TypeOf(int32(0)))
+	assert.Equal(t, []reflect.Type{reflect.TypeOf("")}, types)
+}
+
+func Test_Context_Provider__should_return_provider_metadata(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(newTestSearchClient)
+		m.Add(newTestSearchIndexer)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, ok := ctx.Provider(reflect.TypeOf(int32(0)))
+	if assert.True(t, ok) {
+		assert.Equal(t, "github.com/ivankorobkov/di.newTestSearchIndexer", p.Name)
+		assert.Equal(t, []reflect.Type{reflect.TypeOf(&testSearchClient{})}, p.Deps)
+		assert.False(t, p.IsInstance)
+	}
+
+	_, ok = ctx.Provider(reflect.TypeOf(""))
+	assert.False(t, ok)
+}
+
+func Test_Context_DepsOf__should_return_provider_dependency_types(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.AddInstance(true)
+		m.Add(func(s string, b bool) int32 { return 0 })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deps := ctx.DepsOf(reflect.TypeOf(int32(0)))
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(""), reflect.TypeOf(true)}, deps)
+	assert.Nil(t, ctx.DepsOf(reflect.TypeOf(int64(0))))
+}
+
+func Test_Context_ProvidedTypes__should_return_sorted_provided_types(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(newTestSearchClient)
+		m.AddPrivate(func() int32 { return 1 })
+		m.AddInstance("hello")
+	}, func(m *Module) {
+		m.AddInstance(true)
+		m.AddGroupInstances(1, 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []reflect.Type{
+		reflect.TypeOf(&testSearchClient{}),
+		reflect.TypeOf(true),
+		reflect.TypeOf(""),
+	}, ctx.ProvidedTypes())
+}
+
+type testPlugin struct {
+	Name string
+}
+
+func (p *testPlugin) PluginName() string { return p.Name }
+
+type testPluginIface interface {
+	PluginName() string
+}
+
+func Test_Module_AddGroupInstances__should_resolve_group_as_slice_dependency(t *testing.T) {
+	type Registry struct {
+		Plugins []*testPlugin
+		Ifaces  []testPluginIface
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddGroupInstances(&testPlugin{Name: "a"}, &testPlugin{Name: "b"}, &testPlugin{Name: "c"})
+		m.Add(func(plugins []*testPlugin, ifaces []testPluginIface) *Registry {
+			return &Registry{Plugins: plugins, Ifaces: ifaces}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var registry *Registry
+	ctx.MustGet(&registry)
+
+	if assert.Len(t, registry.Plugins, 3) {
+		assert.Equal(t, "a", registry.Plugins[0].Name)
+		assert.Equal(t, "b", registry.Plugins[1].Name)
+		assert.Equal(t, "c", registry.Plugins[2].Name)
+	}
+	assert.Len(t, registry.Ifaces, 3)
+
+	var plugins []*testPlugin
+	assert.True(t, ctx.GetAll(&plugins))
+	assert.Len(t, plugins, 3)
+}
+
+func testPluginProvider(name string) func() testPluginIface {
+	return func() testPluginIface { return &testPlugin{Name: name} }
+}
+
+func Test_Module_AddOrdered__should_resolve_group_in_explicit_order(t *testing.T) {
+	var plugins []testPluginIface
+	_, err := NewContext(func(m *Module) {
+		m.AddOrdered(20, testPluginProvider("auth"))
+		m.AddOrdered(30, testPluginProvider("handler"))
+		m.Invoke(func(ifaces []testPluginIface) { plugins = ifaces })
+	}, func(m *Module) {
+		m.AddOrdered(10, testPluginProvider("logging"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, plugin := range plugins {
+		names = append(names, plugin.PluginName())
+	}
+	assert.Equal(t, []string{"logging", "auth", "handler"}, names)
+}
+
+func newTestSlowClient(release chan struct{}) *testSearchClient {
+	<-release
+	return &testSearchClient{}
+}
+
+func Test_NewContextWithContext__should_abort_build_with_in_flight_provider(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	build, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := NewContextWithContext(build, func(m *Module) {
+		m.AddInstance(release)
+		m.Add(newTestSlowClient)
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "di: build aborted, provider=github.com/ivankorobkov/di.newTestSlowClient")
+}
+
+func Test_NewContextWithContext__should_inject_build_context_into_providers(t *testing.T) {
+	build, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	observed := make(chan error, 1)
+	_, err := NewContextWithContext(build, func(m *Module) {
+		m.Add(func(ctx context.Context) (*testSearchClient, error) {
+			select {
+			case <-ctx.Done():
+				observed <- ctx.Err()
+				return nil, ctx.Err()
+			case <-time.After(time.Second):
+				return &testSearchClient{}, nil
+			}
+		})
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	select {
+	case err := <-observed:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		t.Fatal("provider did not observe the build deadline")
+	}
+}
+
+func Test_NewContextWithOptions__should_cancel_in_flight_providers_on_first_parallel_error(t *testing.T) {
+	buildErr := errors.New("build error")
+	observed := make(chan error, 1)
+
+	started := time.Now()
+	_, err := NewContextWithOptions(Options{Parallel: true}, func(m *Module) {
+		m.Add(func() (int32, error) {
+			time.Sleep(10 * time.Millisecond)
+			return 0, buildErr
+		})
+		m.Add(func(ctx context.Context) (*testSearchClient, error) {
+			select {
+			case <-ctx.Done():
+				observed <- ctx.Err()
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return &testSearchClient{}, nil
+			}
+		})
+	})
+
+	assert.Equal(t, buildErr, err)
+	assert.Less(t, time.Since(started), time.Second)
+	select {
+	case err := <-observed:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("slow provider was not cancelled")
+	}
+}
+
+func Test_NewContextWithOptions__should_build_dependencies_first_in_parallel(t *testing.T) {
+	ctx, err := NewContextWithOptions(Options{Parallel: true}, func(m *Module) {
+		m.AddInstance("hello")
+		m.Add(func(s string) int32 { return int32(len(s)) })
+		m.Add(func(i int32) int64 { return int64(i) * 2 })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var i int64
+	ctx.MustGet(&i)
+	assert.Equal(t, int64(10), i)
+}
+
+type testRegistryResolver struct {
+	registry map[reflect.Type]interface{}
+}
+
+func (r *testRegistryResolver) Resolve(typ reflect.Type) (interface{}, bool) {
+	instance, ok := r.registry[typ]
+	return instance, ok
+}
+
+func Test_Module_AddTransient__should_build_new_instance_for_each_get_and_dependant(t *testing.T) {
+	type Builder struct{ ID int }
+	type Client struct{ Builder *Builder }
+	type Server struct{ Builder *Builder }
+
+	n := 0
+	ctx, err := NewContext(func(m *Module) {
+		m.AddTransient(func() *Builder { n++; return &Builder{ID: n} })
+		m.Add(func(b *Builder) *Client { return &Client{Builder: b} })
+		m.Add(func(b *Builder) *Server { return &Server{Builder: b} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := MustGet[*Client](ctx)
+	server := MustGet[*Server](ctx)
+	assert.NotSame(t, client.Builder, server.Builder)
+
+	b0 := MustGet[*Builder](ctx)
+	b1 := MustGet[*Builder](ctx)
+	assert.NotSame(t, b0, b1)
+	assert.Equal(t, 4, n)
+	assert.NotContains(t, ctx.InstanceSlice, b0)
+}
+
+func Test_Context_Replace__should_return_error_on_transient_provider(t *testing.T) {
+	type Builder struct{}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddTransient(func() *Builder { return &Builder{} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctx.Replace(&Builder{})
+	assert.EqualError(t, err, "di: provider is transient, type=*di.Builder")
+	err = ctx.Evict(reflect.TypeOf(&Builder{}))
+	assert.EqualError(t, err, "di: provider is transient, type=*di.Builder")
+}
+
+func Test_NewContextWithOptions__should_warn_when_lifecycle_service_depends_on_transient_provider(t *testing.T) {
+	type Builder struct{}
+
+	logger := &testLogger{}
+	_, err := NewContextWithOptions(Options{
+		Logger:        logger,
+		WarningPolicy: map[string]WarningPolicy{WarningUnusedProviders: PolicyIgnore},
+	}, func(m *Module) {
+		m.AddTransient(func() *Builder { return &Builder{} })
+		m.Add(func(*Builder) *testAppService { return &testAppService{} })
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Warning: lifecycle service depends on transient provider, " +
+		"service=*di.testAppService, dep=*di.Builder"}, logger.lines)
+}
+
+func Test_Module_AddAfterAll__should_build_provider_last_with_all_instances(t *testing.T) {
+	type Monitor struct {
+		Instances []interface{}
+	}
+
+	built := []string{}
+	ctx, err := NewContext(func(m *Module) {
+		m.AddAfterAll(func(instances []interface{}) *Monitor {
+			built = append(built, "monitor")
+			return &Monitor{Instances: instances}
+		})
+		m.Add(func() string {
+			built = append(built, "string")
+			return "hello"
+		})
+	}, func(m *Module) {
+		m.Add(func() int {
+			built = append(built, "int")
+			return 123
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var monitor *Monitor
+	ctx.MustGet(&monitor)
+
+	assert.Len(t, built, 3)
+	assert.Equal(t, "monitor", built[2])
+	assert.Contains(t, monitor.Instances, "hello")
+	assert.Contains(t, monitor.Instances, 123)
+}
+
+func Test_Module_AddAfterAll__should_return_error_on_dependency_on_after_all_provider(t *testing.T) {
+	type Monitor struct{}
+
+	_, err := NewContext(func(m *Module) {
+		m.AddAfterAll(func() *Monitor { return &Monitor{} })
+		m.Add(func(*Monitor) string { return "hello" })
+	})
+	assert.ErrorContains(t, err, "di: cannot depend on an after-all provider, dep=*di.Monitor")
+}
+
+func Test_Module_AddResolver__should_resolve_types_without_providers(t *testing.T) {
+	resolver := &testRegistryResolver{registry: map[reflect.Type]interface{}{
+		reflect.TypeOf(""): "from registry",
+	}}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddResolver(resolver)
+		m.Add(func(s string) *testPlugin { return &testPlugin{Name: s} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var plugin *testPlugin
+	ctx.MustGet(&plugin)
+	assert.Equal(t, "from registry", plugin.Name)
+	assert.Equal(t, []Resolver{resolver}, ctx.Resolvers)
+}
+
+func Test_Module_AddResolver__should_return_error_when_resolver_returns_invalid_instance(t *testing.T) {
+	resolver := &testRegistryResolver{registry: map[reflect.Type]interface{}{
+		reflect.TypeOf(""): 123,
+	}}
+
+	_, err := NewContext(func(m *Module) {
+		m.AddResolver(resolver)
+		m.Add(func(s string) *testPlugin { return &testPlugin{Name: s} })
+	})
+	assert.Contains(t, err.Error(), "di: resolver returned invalid instance, type=string")
+}
+
+type testPostgresStore struct{}
+
+func (*testPostgresStore) Load() string { return "postgres" }
+
+func newTestPostgresStore() *testPostgresStore { return &testPostgresStore{} }
+
+func Test_Module_AddAs__should_provide_result_as_interface(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddAs(newTestPostgresStore, (*testStore)(nil))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var store testStore
+	ctx.MustGet(&store)
+	assert.Equal(t, "postgres", store.Load())
+	assert.False(t, ctx.Get(new(*testPostgresStore)))
+}
+
+func Test_Context_HasInterface__should_check_interface_bindings(t *testing.T) {
+	ctx, err := NewContext(testAsPostgresModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, ctx.HasInterface((*testStore)(nil)))
+	assert.False(t, ctx.HasInterface((*testBackend)(nil)))
+	assert.False(t, ctx.HasInterface(&testPostgresStore{}))
+}
+
+func Test_Context_GetAs__should_select_instance_by_hint(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(newTestPostgresStore)
+		m.AddInstance(testMemoryStore{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var store testStore
+	assert.True(t, ctx.GetAs(&store, reflect.TypeOf(&testPostgresStore{})))
+	assert.Equal(t, "postgres", store.Load())
+
+	assert.False(t, ctx.GetAs(&store, reflect.TypeOf(&testSearchClient{})))
+}
+
+func testAsPostgresModule(m *Module) {
+	m.AddAs(newTestPostgresStore, (*testStore)(nil))
+}
+
+func testAsMemoryModule(m *Module) {
+	m.AddInstanceAs(testMemoryStore{}, (*testStore)(nil))
+}
+
+func Test_NewContext__should_return_error_on_duplicate_interface_bindings(t *testing.T) {
+	_, err := NewContext(testAsPostgresModule, testAsMemoryModule)
+
+	assert.Contains(t, err.Error(), "di: duplicate provider, type=di.testStore")
+	assert.Contains(t, err.Error(), "impl0=")
+	assert.Contains(t, err.Error(), "*di.testPostgresStore")
+	assert.Contains(t, err.Error(), "di.testMemoryStore")
+	assert.Contains(t, err.Error(), "di.testAsPostgresModule")
+	assert.Contains(t, err.Error(), "di.testAsMemoryModule")
+}
+
+func Test_Context_MustInvoke__should_call_function_with_injected_deps(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(":8080")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := ""
+	ctx.MustInvoke(func(s string) { addr = s })
+	assert.Equal(t, ":8080", addr)
+
+	assert.PanicsWithError(t, "di: no provider, type=int32", func() {
+		ctx.MustInvoke(func(i int32) {})
+	})
+}
+
+func Test_Context_Invoke__should_return_error_on_non_function(t *testing.T) {
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctx.Invoke(42)
+	assert.EqualError(t, err, "di: invoke must be a function: int")
+
+	err = ctx.Invoke(func() int { return 0 })
+	assert.ErrorContains(t, err, "di: invoke must return nothing or error")
+	assert.PanicsWithError(t, "di: invoke must be a function: int", func() { ctx.MustInvoke(42) })
+}
+
+type testConfiguredServer struct {
+	Addr string
+}
+
+func (s *testConfiguredServer) Configure(addr string) error {
+	if addr == "" {
+		return errors.New("empty address")
+	}
+	s.Addr = addr
+	return nil
+}
+
+func Test_Module_Invoke__should_call_function_with_injected_deps(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(":8080")
+		m.Add(func() *testConfiguredServer { return &testConfiguredServer{} })
+		m.Invoke((*testConfiguredServer).Configure)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server *testConfiguredServer
+	ctx.MustGet(&server)
+	assert.Equal(t, ":8080", server.Addr)
+}
+
+func Test_Module_Invoke__should_abort_build_on_error(t *testing.T) {
+	_, err := NewContext(func(m *Module) {
+		m.AddInstance("")
+		m.Add(func() *testConfiguredServer { return &testConfiguredServer{} })
+		m.Invoke((*testConfiguredServer).Configure)
+	})
+
+	assert.EqualError(t, err, "empty address")
+}
+
+type testFallbackConfig struct {
+	Source string
+}
+
+func Test_Module_AddFallback__should_use_secondary_when_primary_is_not_available(t *testing.T) {
+	newEnvConfig := func() (*testFallbackConfig, error) {
+		return nil, ErrNotAvailable
+	}
+	newFileConfig := func(path string) (*testFallbackConfig, error) {
+		return &testFallbackConfig{Source: path}, nil
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("config.yaml")
+		m.AddFallback(newEnvConfig, newFileConfig)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config *testFallbackConfig
+	ctx.MustGet(&config)
+	assert.Equal(t, "config.yaml", config.Source)
+}
+
+func Test_Module_AddFallback__should_use_primary_when_available(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddFallback(
+			func() *testFallbackConfig { return &testFallbackConfig{Source: "env"} },
+			func() *testFallbackConfig { return &testFallbackConfig{Source: "file"} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config *testFallbackConfig
+	ctx.MustGet(&config)
+	assert.Equal(t, "env", config.Source)
+}
+
+type testTenantID string
+
+type testTenantDB struct {
+	DSN    string
+	Tenant testTenantID
+}
+
+func Test_Module_AddKeyed__should_cache_instances_by_keys(t *testing.T) {
+	newTenantDB := func(dsn string, tenant testTenantID) *testTenantDB {
+		return &testTenantDB{DSN: dsn, Tenant: tenant}
+	}
+	tenantKey := func(tenant testTenantID) string { return string(tenant) }
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("postgres://localhost")
+		m.AddKeyed(tenantKey, newTenantDB)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var factory func(testTenantID) *testTenantDB
+	ctx.MustGet(&factory)
+
+	a := factory("a")
+	b := factory("b")
+	assert.Same(t, a, factory("a"))
+	assert.NotSame(t, a, b)
+	assert.Equal(t, &testTenantDB{DSN: "postgres://localhost", Tenant: "a"}, a)
+	assert.Equal(t, &testTenantDB{DSN: "postgres://localhost", Tenant: "b"}, b)
+}
+
+func Test_Module_AddKeyed__should_panic_on_nil_key_function(t *testing.T) {
+	newTenantDB := func(tenant testTenantID) *testTenantDB { return &testTenantDB{Tenant: tenant} }
+
+	assert.PanicsWithValue(t, "di: key function must be func(di.testTenantID) comparable: <nil>", func() {
+		NewContext(func(m *Module) { m.AddKeyed(nil, newTenantDB) })
+	})
+	assert.PanicsWithValue(t, "di: key function must be func(di.testTenantID) comparable: func(di.testTenantID) string", func() {
+		NewContext(func(m *Module) { m.AddKeyed((func(testTenantID) string)(nil), newTenantDB) })
+	})
+}
+
+type testDBConfig struct {
+	DSN string
+}
+
+type testAppConfig struct {
+	DB   testDBConfig
+	Port int
+}
+
+func Test_Module_AddSubField__should_provide_field_of_parent_instance(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(&testAppConfig{DB: testDBConfig{DSN: "postgres://localhost"}, Port: 8080})
+		m.AddSubField((*testAppConfig)(nil), "DB")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var db testDBConfig
+	ctx.MustGet(&db)
+	assert.Equal(t, "postgres://localhost", db.DSN)
+}
+
+type testHandlerKind string
+
+type testHandler interface {
+	Key() testHandlerKind
+}
+
+type testKindHandler struct {
+	kind testHandlerKind
+}
+
+func (h *testKindHandler) Key() testHandlerKind { return h.kind }
+
+func Test_Module_AddGroupInstances__should_resolve_group_as_keyed_map(t *testing.T) {
+	var handlers map[testHandlerKind]testHandler
+	_, err := NewContext(func(m *Module) {
+		m.AddGroupInstances(
+			&testKindHandler{kind: "create"},
+			&testKindHandler{kind: "update"},
+			&testKindHandler{kind: "delete"})
+		m.Invoke(func(h map[testHandlerKind]testHandler) { handlers = h })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, handlers, 3)
+	for _, kind := range []testHandlerKind{"create", "update", "delete"} {
+		if assert.Contains(t, handlers, kind) {
+			assert.Equal(t, kind, handlers[kind].Key())
+		}
+	}
+}
+
+func Test_Module_AddGroupInstances__should_return_error_on_duplicate_keys(t *testing.T) {
+	_, err := NewContext(func(m *Module) {
+		m.AddGroupInstances(&testKindHandler{kind: "create"}, &testKindHandler{kind: "create"})
+		m.Invoke(func(map[testHandlerKind]testHandler) {})
+	})
+
+	assert.EqualError(t, err, "di: duplicate group key, type=map[di.testHandlerKind]di.testHandler, key=create")
+}
@@ -0,0 +1,72 @@
+package di
+
+import (
+	"reflect"
+	"sort"
+)
+
+// GraphDiff lists the provider types which differ between two contexts, sorted by type names.
+type GraphDiff struct {
+	Added   []reflect.Type
+	Removed []reflect.Type
+	Changed []reflect.Type // Types whose provider dependencies changed.
+}
+
+// Empty returns true when the contexts provide the same types with the same dependencies.
+func (d GraphDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffGraphs compares the public module providers of two contexts.
+func DiffGraphs(old, new *Context) GraphDiff {
+	diff := GraphDiff{
+		Added:   []reflect.Type{},
+		Removed: []reflect.Type{},
+		Changed: []reflect.Type{},
+	}
+
+	for typ, p := range new.Providers {
+		if p.Module == new.builtin {
+			continue
+		}
+
+		p0, ok := old.Providers[typ]
+		switch {
+		case !ok || p0.Module == old.builtin:
+			diff.Added = append(diff.Added, typ)
+		case !sameDeps(p0.Deps, p.Deps):
+			diff.Changed = append(diff.Changed, typ)
+		}
+	}
+	for typ, p0 := range old.Providers {
+		if p0.Module == old.builtin {
+			continue
+		}
+		if p, ok := new.Providers[typ]; !ok || p.Module == new.builtin {
+			diff.Removed = append(diff.Removed, typ)
+		}
+	}
+
+	sortTypes(diff.Added)
+	sortTypes(diff.Removed)
+	sortTypes(diff.Changed)
+	return diff
+}
+
+func sameDeps(deps0, deps1 []reflect.Type) bool {
+	if len(deps0) != len(deps1) {
+		return false
+	}
+	for i, dep := range deps0 {
+		if deps1[i] != dep {
+			return false
+		}
+	}
+	return true
+}
+
+func sortTypes(types []reflect.Type) {
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].String() < types[j].String()
+	})
+}
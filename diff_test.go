@@ -0,0 +1,37 @@
+package di
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DiffGraphs__should_return_added_removed_and_changed_providers(t *testing.T) {
+	old, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.AddInstance(true)
+		m.Add(func(s string) int32 { return 0 })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	new, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.AddInstance(int64(1))
+		m.Add(func(s string, i int64) int32 { return 0 })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := DiffGraphs(old, new)
+	assert.Equal(t, GraphDiff{
+		Added:   []reflect.Type{reflect.TypeOf(int64(0))},
+		Removed: []reflect.Type{reflect.TypeOf(true)},
+		Changed: []reflect.Type{reflect.TypeOf(int32(0))},
+	}, diff)
+	assert.False(t, diff.Empty())
+	assert.True(t, DiffGraphs(old, old).Empty())
+}
@@ -0,0 +1,109 @@
+package di
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// MarshalDot returns the provider graph in the Graphviz DOT format,
+// edges point from dependants to dependencies.
+func (ctx *Context) MarshalDot() string {
+	return ctx.marshalDot(func(*Provider) bool { return true })
+}
+
+// MarshalDotFor returns the subgraph of a type provider and its transitive dependencies
+// in the Graphviz DOT format.
+func (ctx *Context) MarshalDotFor(typ reflect.Type) string {
+	roots := []*Provider{}
+	if p, ok := ctx.Providers[typ]; ok {
+		roots = append(roots, p)
+	}
+
+	reached := ctx.reachable(roots)
+	return ctx.marshalDot(func(p *Provider) bool { return reached[p] })
+}
+
+// ExplainPlan writes the construction of module instances as Go pseudocode in the construction order,
+// for example, server := di.newServer(db, cache) // module=app.ServerModule.
+func (ctx *Context) ExplainPlan(w io.Writer) error {
+	for _, p := range ctx.InstanceProviders {
+		var line string
+		if p.IsInstance {
+			line = fmt.Sprintf("%v := instance", varName(p.Type))
+		} else {
+			args := []string{}
+			for _, dep := range p.Deps {
+				args = append(args, varName(dep))
+			}
+			line = fmt.Sprintf("%v := %v(%v)", varName(p.Type), shortName(p.Name), strings.Join(args, ", "))
+		}
+
+		if _, err := fmt.Fprintf(w, "%v // module=%v\n", line, shortName(p.Module.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varName returns a variable name for a type, for example, db for *sql.DB.
+func varName(typ reflect.Type) string {
+	if elem, ok := optionalElem(typ); ok {
+		typ = elem
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return varName(typ.Elem())
+	case reflect.Slice:
+		return varName(typ.Elem()) + "s"
+	}
+
+	name := typ.Name()
+	if name == "" {
+		return "v"
+	}
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// shortName returns a function name without the package path, for example, di.newServer.
+func shortName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// marshalDot writes the included providers and their dependencies in the construction order,
+// transient providers are dashed nodes, and built-in providers are omitted.
+func (ctx *Context) marshalDot(include func(p *Provider) bool) string {
+	b := strings.Builder{}
+	b.WriteString("digraph di {\n")
+
+	transient := map[*Provider]bool{}
+
+	for _, p := range ctx.InstanceProviders {
+		if !include(p) {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\t%q;\n", p.Type.String())
+		for _, dep := range p.Deps {
+			depProviders, _, _ := ctx.resolve(p.Module, dep)
+			for _, depProvider := range depProviders {
+				if depProvider.Module == ctx.builtin {
+					continue
+				}
+				if depProvider.Transient && !transient[depProvider] {
+					transient[depProvider] = true
+					fmt.Fprintf(&b, "\t%q [style=dashed];\n", depProvider.Type.String())
+				}
+				fmt.Fprintf(&b, "\t%q -> %q;\n", p.Type.String(), depProvider.Type.String())
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
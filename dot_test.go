@@ -0,0 +1,78 @@
+package di
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Context_MarshalDotFor__should_exclude_unrelated_providers(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(newTestSearchClient)
+		m.Add(newTestSearchIndexer)
+		m.AddInstance("unrelated")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := ctx.MarshalDotFor(reflect.TypeOf(int32(0)))
+	assert.Equal(t, `digraph di {
+	"*di.testSearchClient";
+	"int32";
+	"int32" -> "*di.testSearchClient";
+}
+`, dot)
+	assert.Contains(t, ctx.MarshalDot(), `"string";`)
+}
+
+func Test_Context_MarshalDot__should_mark_transient_providers(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddTransient(newTestSearchClient)
+		m.Add(newTestSearchIndexer)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := ctx.MarshalDotFor(reflect.TypeOf(int32(0)))
+	assert.Equal(t, `digraph di {
+	"int32";
+	"*di.testSearchClient" [style=dashed];
+	"int32" -> "*di.testSearchClient";
+}
+`, dot)
+}
+
+type testPlanDB struct{}
+type testPlanCache struct{}
+type testPlanService struct{}
+
+func newTestPlanService(db *testPlanDB, cache *testPlanCache) *testPlanService {
+	return &testPlanService{}
+}
+
+func testPlanModule(m *Module) {
+	m.Add(newTestPlanService)
+	m.Add(func() *testPlanCache { return &testPlanCache{} })
+	m.AddInstance(&testPlanDB{})
+}
+
+func Test_Context_ExplainPlan__should_write_constructors_in_dependency_order(t *testing.T) {
+	ctx, err := NewContext(testPlanModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &bytes.Buffer{}
+	if err := ctx.ExplainPlan(b); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `testPlanDB := instance // module=di.testPlanModule
+testPlanCache := di.testPlanModule.func1() // module=di.testPlanModule
+testPlanService := di.newTestPlanService(testPlanDB, testPlanCache) // module=di.testPlanModule
+`, b.String())
+}
@@ -0,0 +1,53 @@
+package di
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Env reads typed configuration values from environment variables with a name prefix,
+// it is provided by Module.AddEnv, for example, env.Int("PORT") reads APP_PORT for the APP_ prefix.
+type Env struct {
+	Prefix string
+}
+
+// String returns a string variable value, or an error when the variable is not set.
+func (e Env) String(name string) (string, error) {
+	key := e.Prefix + name
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("di: env variable is not set, name=%v", key)
+	}
+	return value, nil
+}
+
+// Int returns an int variable value.
+func (e Env) Int(name string) (int, error) {
+	return parseEnv(e, name, strconv.Atoi)
+}
+
+// Bool returns a bool variable value, for example, true, false, 1 or 0.
+func (e Env) Bool(name string) (bool, error) {
+	return parseEnv(e, name, strconv.ParseBool)
+}
+
+// Duration returns a duration variable value, for example, 5s or 100ms.
+func (e Env) Duration(name string) (time.Duration, error) {
+	return parseEnv(e, name, time.ParseDuration)
+}
+
+func parseEnv[T any](e Env, name string, parse func(string) (T, error)) (T, error) {
+	var zero T
+	s, err := e.String(name)
+	if err != nil {
+		return zero, err
+	}
+
+	value, err := parse(s)
+	if err != nil {
+		return zero, fmt.Errorf("di: invalid env variable, name=%v%v: %w", e.Prefix, name, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,68 @@
+package di
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEnvConfig struct {
+	DSN     string
+	Port    int
+	Debug   bool
+	Timeout time.Duration
+}
+
+func newTestEnvConfig(env Env) (*testEnvConfig, error) {
+	config := &testEnvConfig{}
+	var err error
+	if config.DSN, err = env.String("DSN"); err != nil {
+		return nil, err
+	}
+	if config.Port, err = env.Int("PORT"); err != nil {
+		return nil, err
+	}
+	if config.Debug, err = env.Bool("DEBUG"); err != nil {
+		return nil, err
+	}
+	if config.Timeout, err = env.Duration("TIMEOUT"); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func Test_Module_AddEnv__should_provide_typed_env_values(t *testing.T) {
+	t.Setenv("TEST_APP_DSN", "postgres://localhost")
+	t.Setenv("TEST_APP_PORT", "8080")
+	t.Setenv("TEST_APP_DEBUG", "true")
+	t.Setenv("TEST_APP_TIMEOUT", "5s")
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddEnv("TEST_APP_")
+		m.Add(newTestEnvConfig)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config *testEnvConfig
+	ctx.MustGet(&config)
+	assert.Equal(t, &testEnvConfig{
+		DSN:     "postgres://localhost",
+		Port:    8080,
+		Debug:   true,
+		Timeout: 5 * time.Second,
+	}, config)
+}
+
+func Test_Env__should_return_error_for_missing_and_invalid_values(t *testing.T) {
+	t.Setenv("TEST_APP_PORT", "http")
+	env := Env{Prefix: "TEST_APP_"}
+
+	_, err := env.String("MISSING")
+	assert.EqualError(t, err, "di: env variable is not set, name=TEST_APP_MISSING")
+
+	_, err = env.Int("PORT")
+	assert.ErrorContains(t, err, "di: invalid env variable, name=TEST_APP_PORT")
+}
@@ -0,0 +1,47 @@
+package di
+
+import (
+	"reflect"
+)
+
+// Add0 adds a new provider without dependencies, whose signature is checked at compile time.
+// Add1 to Add4 add providers with dependencies, for example, di.Add2(m, newServer)
+// for newServer(*DB, *Config) *Server, a parameter struct which embeds In receives more dependencies.
+func Add0[T any](m *Module, f func() T) { m.add(newProvider(m, f)) }
+
+// Add1 adds a new provider with one dependency, see Add0.
+func Add1[T, A any](m *Module, f func(A) T) { m.add(newProvider(m, f)) }
+
+// Add2 adds a new provider with two dependencies, see Add0.
+func Add2[T, A, B any](m *Module, f func(A, B) T) { m.add(newProvider(m, f)) }
+
+// Add3 adds a new provider with three dependencies, see Add0.
+func Add3[T, A, B, C any](m *Module, f func(A, B, C) T) { m.add(newProvider(m, f)) }
+
+// Add4 adds a new provider with four dependencies, see Add0.
+func Add4[T, A, B, C, D any](m *Module, f func(A, B, C, D) T) { m.add(newProvider(m, f)) }
+
+// AddErr0 adds a new provider which returns an error, whose signature is checked at compile time.
+// AddErr1 to AddErr4 add providers with dependencies, for example, di.AddErr1(m, openDB)
+// for openDB(*Config) (*DB, error).
+func AddErr0[T any](m *Module, f func() (T, error)) { m.add(newProvider(m, f)) }
+
+// AddErr1 adds a new provider with one dependency, see AddErr0.
+func AddErr1[T, A any](m *Module, f func(A) (T, error)) { m.add(newProvider(m, f)) }
+
+// AddErr2 adds a new provider with two dependencies, see AddErr0.
+func AddErr2[T, A, B any](m *Module, f func(A, B) (T, error)) { m.add(newProvider(m, f)) }
+
+// AddErr3 adds a new provider with three dependencies, see AddErr0.
+func AddErr3[T, A, B, C any](m *Module, f func(A, B, C) (T, error)) { m.add(newProvider(m, f)) }
+
+// AddErr4 adds a new provider with four dependencies, see AddErr0.
+func AddErr4[T, A, B, C, D any](m *Module, f func(A, B, C, D) (T, error)) { m.add(newProvider(m, f)) }
+
+// AddInstance adds a new instance provider of type T, which may be an interface,
+// for example, di.AddInstance[Cache](m, redisCache).
+func AddInstance[T any](m *Module, instance T) {
+	p := newInstanceProvider(m, instance)
+	p.Type = reflect.TypeOf((*T)(nil)).Elem()
+	m.add(p)
+}
@@ -0,0 +1,52 @@
+package di
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testGenericServer struct {
+	Addr  string
+	Store testStore
+}
+
+func Test_Add2__should_add_typed_provider(t *testing.T) {
+	store := &testMemoryStore{}
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance("localhost:8080")
+		AddInstance[testStore](m, store)
+		Add2(m, func(addr string, store testStore) *testGenericServer {
+			return &testGenericServer{Addr: addr, Store: store}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := MustGet[*testGenericServer](ctx)
+	assert.Equal(t, "localhost:8080", server.Addr)
+	assert.Same(t, store, server.Store)
+}
+
+func Test_Add1__should_add_provider_with_non_struct_dependency(t *testing.T) {
+	type DB struct{ Size int }
+
+	ctx, err := NewContext(func(m *Module) {
+		AddInstance(m, &DB{Size: 3})
+		Add1(m, func(db *DB) int { return db.Size })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 3, MustGet[int](ctx))
+}
+
+func Test_AddErr0__should_return_provider_error(t *testing.T) {
+	_, err := NewContext(func(m *Module) {
+		AddErr0(m, func() (string, error) { return "", errors.New("failed") })
+	})
+	assert.EqualError(t, err, "failed")
+}
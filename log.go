@@ -0,0 +1,81 @@
+package di
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lifecycle event statuses.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// LifecycleEvent describes a service start or stop.
+type LifecycleEvent struct {
+	Phase    string
+	Service  string
+	Status   string
+	Duration time.Duration
+	Err      error
+}
+
+// EventLogger is a logger which receives structured per-service lifecycle events
+// instead of the text messages.
+type EventLogger interface {
+	Logger
+	LogEvent(e LifecycleEvent)
+}
+
+// JSONLogger returns a logger which writes the messages and the lifecycle events
+// as JSON objects, one per line.
+func JSONLogger(w io.Writer) Logger {
+	return &jsonLogger{w: w}
+}
+
+type jsonLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type jsonEvent struct {
+	Phase   string `json:"phase,omitempty"`
+	Service string `json:"service,omitempty"`
+	Status  string `json:"status,omitempty"`
+	DurMs   *int64 `json:"dur_ms,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Msg     string `json:"msg,omitempty"`
+}
+
+func (l *jsonLogger) Println(v ...interface{}) {
+	l.write(jsonEvent{Msg: strings.TrimSuffix(fmt.Sprintln(v...), "\n")})
+}
+
+func (l *jsonLogger) LogEvent(e LifecycleEvent) {
+	ms := e.Duration.Milliseconds()
+	event := jsonEvent{
+		Phase:   e.Phase,
+		Service: e.Service,
+		Status:  e.Status,
+		DurMs:   &ms,
+	}
+	if e.Err != nil {
+		event.Error = e.Err.Error()
+	}
+	l.write(event)
+}
+
+func (l *jsonLogger) write(event jsonEvent) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(b, '\n'))
+}
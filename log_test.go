@@ -0,0 +1,43 @@
+package di
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JSONLogger__should_log_structured_lifecycle_events(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(&testAppService{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	app := &App{Context: ctx, Logger: JSONLogger(buf), Verbose: true}
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		event := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, map[string]interface{}{"msg": "Starting..."}, events[0])
+	assert.Equal(t, "start", events[1]["phase"])
+	assert.Equal(t, "*di.testAppService", events[1]["service"])
+	assert.Equal(t, "ok", events[1]["status"])
+	assert.Contains(t, events[1], "dur_ms")
+	assert.Equal(t, map[string]interface{}{"msg": "Started."}, events[2])
+}
@@ -1,10 +1,20 @@
 package di
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 )
 
+// StopHook is a teardown function which is not tied to any instance.
+type StopHook func(ctx context.Context) error
+
+// Resolver resolves instances of the types which have no providers,
+// for example, from an external registry.
+type Resolver interface {
+	Resolve(typ reflect.Type) (instance interface{}, ok bool)
+}
+
 // ModuleFunc defines a module provider.
 type ModuleFunc func(*Module)
 
@@ -18,6 +28,11 @@ type Module struct {
 	Imports   []ModuleFunc
 	Providers []*Provider
 	Deps      []reflect.Type
+	StopHooks []StopHook
+	Resolvers []Resolver
+	Invokes   []*Provider // Functions called after all instances are initialized.
+
+	override bool // Its providers supersede the providers of the same types, see Options.Overrides.
 }
 
 func newModule(f ModuleFunc) *Module {
@@ -37,21 +52,180 @@ func (m *Module) Add(f interface{}) {
 	m.add(p)
 }
 
+// AddAs adds a new provider which provides its result as an interface,
+// the interface is passed as a nil pointer, for example, m.AddAs(newRedisCache, (*Cache)(nil)).
+func (m *Module) AddAs(f interface{}, iface interface{}) {
+	p := newProvider(m, f)
+	bindAs(p, iface)
+	m.add(p)
+}
+
+// AddFallback adds a provider which uses the primary function unless it returns ErrNotAvailable,
+// and then the secondary one. Both functions must return the same type, and the dependencies
+// of both are injected.
+func (m *Module) AddFallback(primary interface{}, secondary interface{}) {
+	p := newFallbackProvider(newProvider(m, primary), newProvider(m, secondary))
+	m.add(p)
+}
+
+// AddKeyed adds a provider of a factory which returns one instance per key, for example,
+// m.AddKeyed(keyFn, newTenantDB) with newTenantDB(*Config, TenantID) *TenantDB provides
+// func(TenantID) *TenantDB. The last function parameter is passed to the factory, the other ones
+// are injected, and the key function derives a comparable cache key from the factory parameter.
+func (m *Module) AddKeyed(keyFn interface{}, f interface{}) {
+	p := newKeyedProvider(m, keyFn, f)
+	m.add(p)
+}
+
+// AddReleasable adds a new provider whose instance can be evicted by Context.Evict,
+// for example, to free memory, and is rebuilt on the next get.
+func (m *Module) AddReleasable(f interface{}) {
+	p := newProvider(m, f)
+	p.Releasable = true
+	m.add(p)
+}
+
+// AddTransient adds a new provider which builds a new instance for each get and dependant,
+// for example, of request builders or buffers. Transient instances are not managed by an app.
+func (m *Module) AddTransient(f interface{}) {
+	p := newProvider(m, f)
+	p.Transient = true
+	m.add(p)
+}
+
+// AddAfterAll adds a new provider which is built strictly after all other providers,
+// and can depend on []interface{} of all built instances, for example, a monitoring service.
+// Other providers cannot depend on it.
+func (m *Module) AddAfterAll(f interface{}) {
+	p := newProvider(m, f)
+	p.AfterAll = true
+	m.add(p)
+}
+
+// AddSubField adds a new provider which extracts a public field from a parent instance,
+// the parent type is passed as a value or a nil pointer, for example, m.AddSubField((*Config)(nil), "DB").
+func (m *Module) AddSubField(parent interface{}, fieldName string) {
+	p := newSubFieldProvider(m, parent, fieldName)
+	m.add(p)
+}
+
+// AddEnv adds an Env provider which reads typed values from environment variables with a name prefix.
+func (m *Module) AddEnv(prefix string) {
+	m.AddInstance(Env{Prefix: prefix})
+}
+
+// AddPrivate adds a new provider which is visible only to the providers of this module.
+// Private providers take precedence over context providers when resolving this module
+// dependencies, so sibling modules can each have their own instance of the same type.
+func (m *Module) AddPrivate(f interface{}) {
+	p := newProvider(m, f)
+	p.Private = true
+	m.add(p)
+}
+
 // AddInstance adds a new instance provider.
+// The instance is registered as is under its own type, functions including
+// func(*Module) values are never called or treated as modules.
 func (m *Module) AddInstance(instance interface{}) {
 	p := newInstanceProvider(m, instance)
 	m.add(p)
 }
 
+// AddInstanceAs adds a new instance provider which provides the instance as an interface,
+// the interface is passed as a nil pointer, for example, m.AddInstanceAs(cache, (*Cache)(nil)).
+func (m *Module) AddInstanceAs(instance interface{}, iface interface{}) {
+	p := newInstanceProvider(m, instance)
+	bindAs(p, iface)
+	m.add(p)
+}
+
+// AddGroupInstances adds instance providers which form a group, the instances may have
+// the same types. A slice dependency, for which there is no provider, resolves to the group
+// instances assignable to the slice element type, for example, []Plugin. A map dependency resolves
+// to the group instances keyed by their Key methods, for example, map[Kind]Handler.
+func (m *Module) AddGroupInstances(instances ...interface{}) {
+	for _, instance := range instances {
+		p := newInstanceProvider(m, instance)
+		p.Group = true
+		m.add(p)
+	}
+}
+
+// AddOrdered adds a new provider to a group, for example, of middlewares. A slice dependency
+// resolves to the group instances ordered by their orders, then by modules and registration.
+func (m *Module) AddOrdered(order int, f interface{}) {
+	p := newProvider(m, f)
+	p.Group = true
+	p.Order = order
+	m.add(p)
+}
+
+// AddNamed adds a new provider of a named binding, so that several instances of the same type
+// can coexist, for example, m.AddNamed("replica", newReplicaDB). The instance is resolved
+// by Context.GetNamed or a []Named[T] dependency, not by a plain T dependency.
+func (m *Module) AddNamed(name string, f interface{}) {
+	p := newProvider(m, f)
+	p.Qualifier = name
+	m.add(p)
+}
+
+// AddNamedInstance adds a new instance provider of a named binding, see AddNamed.
+func (m *Module) AddNamedInstance(name string, instance interface{}) {
+	p := newInstanceProvider(m, instance)
+	p.Qualifier = name
+	m.add(p)
+}
+
 func (m *Module) add(p *Provider) {
 	for _, p0 := range m.Providers {
-		if p0.Type == p.Type {
-			panic(fmt.Errorf("di: duplicate provider, type=%v module=%v", p.Type, m.Name))
+		if p.Group || p0.Group || p0.Qualifier != p.Qualifier {
+			continue
 		}
+		if p0.Type != p.Type {
+			continue
+		}
+		if p.Qualifier != "" {
+			panic(fmt.Errorf("di: duplicate named provider, type=%v name=%v module=%v", p.Type, p.Qualifier, m.Name))
+		}
+		panic(fmt.Errorf("di: duplicate provider, type=%v module=%v", p.Type, m.Name))
 	}
 	m.Providers = append(m.Providers, p)
 }
 
+func (m *Module) privateProvider(typ reflect.Type) *Provider {
+	for _, p := range m.Providers {
+		if p.Private && p.Type == typ {
+			return p
+		}
+	}
+	return nil
+}
+
+// Invoke adds a function which is called once after all instances are initialized,
+// its parameters are injected from the graph, for example, m.Invoke((*Server).Configure).
+// The function returns nothing or an error, the error aborts the context build.
+func (m *Module) Invoke(f interface{}) {
+	p := newInvoker(m, f)
+	m.Invokes = append(m.Invokes, p)
+}
+
+// OnStop adds a stop hook which the app runs after stopping the services,
+// hooks are run in reverse registration order.
+func (m *Module) OnStop(hook StopHook) {
+	if hook == nil {
+		panic("di: nil stop hook")
+	}
+	m.StopHooks = append(m.StopHooks, hook)
+}
+
+// AddResolver adds a resolver which the context consults for the dependencies without providers.
+func (m *Module) AddResolver(r Resolver) {
+	if r == nil {
+		panic("di: nil resolver")
+	}
+	m.Resolvers = append(m.Resolvers, r)
+}
+
 // Dep adds a dependency which will be provided at a context level, not via imported modules.
 func (m *Module) Dep(dep interface{}) {
 	typ := reflect.TypeOf(dep)
@@ -64,6 +238,13 @@ func (m *Module) Dep(dep interface{}) {
 	m.Deps = append(m.Deps, typ)
 }
 
+// ImportAll adds other modules to this module dependencies, duplicate imports panic as in Import.
+func (m *Module) ImportAll(modules ...ModuleFunc) {
+	for _, module := range modules {
+		m.Import(module)
+	}
+}
+
 // Import adds another module to this module dependencies.
 func (m *Module) Import(module ModuleFunc) {
 	if module == nil {
@@ -0,0 +1,241 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Named is an instance of a named binding, a []Named[T] dependency resolves to all named
+// bindings of type T ordered by names, for example, []Named[*sql.DB] of primary and replica.
+type Named[T any] struct {
+	Name  string
+	Value T
+}
+
+// namedBinding is implemented by all Named types.
+type namedBinding interface {
+	bindingType() reflect.Type
+	withBinding(name string, instance interface{}) interface{}
+}
+
+func (n Named[T]) bindingType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (n Named[T]) withBinding(name string, instance interface{}) interface{} {
+	return Named[T]{Name: name, Value: instance.(T)}
+}
+
+// namedKey is a named binding key.
+type namedKey struct {
+	typ  reflect.Type
+	name string
+}
+
+// namedElem returns a binding type when a type is a slice of a Named type.
+func namedElem(typ reflect.Type) (namedBinding, bool) {
+	if typ.Kind() != reflect.Slice {
+		return nil, false
+	}
+	n, ok := reflect.Zero(typ.Elem()).Interface().(namedBinding)
+	return n, ok
+}
+
+// namedDep returns true when a dependency is a []Named[T], optionally wrapped.
+func namedDep(dep reflect.Type) bool {
+	if elem, ok := optionalElem(dep); ok {
+		dep = elem
+	}
+	_, ok := namedElem(dep)
+	return ok
+}
+
+// namedProviders returns the named providers for a []Named[T] dependency ordered by names.
+func (ctx *Context) namedProviders(typ reflect.Type) []*Provider {
+	n, ok := namedElem(typ)
+	if !ok {
+		return nil
+	}
+
+	byNames := map[string]*Provider{}
+	for _, c := range ctx.scopeChain() {
+		for key, p := range c.named {
+			if key.typ != n.bindingType() {
+				continue
+			}
+			if c != ctx {
+				p = ctx.proxyProvider(c, p)
+			}
+			byNames[key.name] = p
+		}
+	}
+
+	providers := []*Provider{}
+	for _, p := range byNames {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Qualifier < providers[j].Qualifier
+	})
+	return providers
+}
+
+// namedSlice returns a []Named[T] of named provider instances.
+func namedSlice(typ reflect.Type, providers []*Provider, instances []interface{}) interface{} {
+	n, _ := namedElem(typ)
+	slice := reflect.MakeSlice(typ, 0, len(instances))
+	for i, instance := range instances {
+		v := n.withBinding(providers[i].Qualifier, instance)
+		slice = reflect.Append(slice, reflect.ValueOf(v))
+	}
+	return slice.Interface()
+}
+
+// GetNamed returns an instance of a named binding from this context.
+func (ctx *Context) GetNamed(name string, dstPtr interface{}) bool {
+	return ctx.injectNamed(reflect.ValueOf(dstPtr).Elem(), name)
+}
+
+// injectNamed sets a value to the instance of a named binding of its type,
+// and returns false when there is no instance.
+func (ctx *Context) injectNamed(v reflect.Value, name string) bool {
+	p := ctx.namedProvider(namedKey{typ: v.Type(), name: name})
+	if p == nil {
+		return false
+	}
+
+	instance, err := ctx.initInstance(p)
+	if err != nil {
+		return false
+	}
+	v.Set(reflect.ValueOf(instance))
+	return true
+}
+
+// MustGetNamed returns an instance of a named binding from this context or panics if absents.
+func (ctx *Context) MustGetNamed(name string, dstPtr interface{}) {
+	if !ctx.GetNamed(name, dstPtr) {
+		panic(fmt.Sprintf("di: no named instance, type=%T, name=%v", dstPtr, name))
+	}
+}
+
+// HasNamed returns true when there is a named binding of a type, the type is passed as a pointer,
+// like to GetNamed, for example, ctx.HasNamed("replica", (**sql.DB)(nil)) or (*Store)(nil).
+func (ctx *Context) HasNamed(name string, dstPtr interface{}) bool {
+	ptr := reflect.TypeOf(dstPtr)
+	if ptr == nil || ptr.Kind() != reflect.Ptr {
+		return false
+	}
+
+	key := namedKey{typ: ptr.Elem(), name: name}
+	for _, c := range ctx.scopeChain() {
+		if _, ok := c.named[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// namedProvider returns the provider of a named binding, in a scope it returns a built-in provider
+// of the nearest parent binding when the scope has none. It returns nil when there is no binding.
+func (ctx *Context) namedProvider(key namedKey) *Provider {
+	if p, ok := ctx.named[key]; ok {
+		return p
+	}
+	for c := ctx.options.parent; c != nil; c = c.options.parent {
+		if p, ok := c.named[key]; ok {
+			return ctx.proxyProvider(c, p)
+		}
+	}
+	return nil
+}
+
+// In is embedded into a provider parameter struct, whose exported fields are injected as dependencies,
+// the fields tagged `di:"name=replica"` are injected with named bindings, for example:
+//
+//	type ServerDeps struct {
+//		di.In
+//		DB *sql.DB `di:"name=replica"`
+//	}
+type In struct{}
+
+var inType = reflect.TypeOf(In{})
+
+// isInStruct returns true when a type is a struct which embeds In.
+func isInStruct(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type == inType {
+			return true
+		}
+	}
+	return false
+}
+
+// inDeps returns the dependencies of an In struct, the named fields depend on named bindings.
+func inDeps(typ reflect.Type) []reflect.Type {
+	deps := []reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Type == inType {
+			continue
+		}
+		if !field.IsExported() {
+			panic(fmt.Sprintf("di: dependency field must be exported: %v.%v", typ, field.Name))
+		}
+
+		dep := field.Type
+		if name := parseInjectTag(field.Tag).name; name != "" {
+			dep = namedDepType(field.Type, name)
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+// newInStruct returns an In struct whose fields are set to the leading args,
+// and the number of the used args.
+func newInStruct(typ reflect.Type, args []interface{}) (reflect.Value, int) {
+	v := reflect.New(typ).Elem()
+	n := 0
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).Type == inType {
+			continue
+		}
+		if args[n] != nil {
+			v.Field(i).Set(reflect.ValueOf(args[n]))
+		}
+		n++
+	}
+	return v, n
+}
+
+// namedDeps maps the named dependency types to their named binding keys.
+var namedDeps sync.Map
+
+// namedDepType returns a dependency type which resolves to a named binding. It is a distinct
+// struct type per binding type and name, so that the dependencies remain reflect types.
+func namedDepType(typ reflect.Type, name string) reflect.Type {
+	dep := reflect.StructOf([]reflect.StructField{{
+		Name: "Value",
+		Type: typ,
+		Tag:  reflect.StructTag("di:" + strconv.Quote("name="+name)),
+	}})
+	namedDeps.Store(dep, namedKey{typ: typ, name: name})
+	return dep
+}
+
+// namedDepKey returns a named binding key when a type is a named dependency type.
+func namedDepKey(typ reflect.Type) (namedKey, bool) {
+	key, ok := namedDeps.Load(typ)
+	if !ok {
+		return namedKey{}, false
+	}
+	return key.(namedKey), true
+}
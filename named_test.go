@@ -0,0 +1,138 @@
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testNamedClient struct {
+	Addr string
+}
+
+func Test_Module_AddNamed__should_provide_several_instances_of_same_type(t *testing.T) {
+	type Pool struct {
+		Clients []Named[*testNamedClient]
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddNamed("primary", func() *testNamedClient { return &testNamedClient{Addr: "primary:5432"} })
+		m.AddNamedInstance("replica", &testNamedClient{Addr: "replica:5432"})
+		m.Add(func(clients []Named[*testNamedClient]) *Pool { return &Pool{Clients: clients} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var primary, replica *testNamedClient
+	assert.True(t, ctx.GetNamed("primary", &primary))
+	assert.True(t, ctx.GetNamed("replica", &replica))
+	assert.Equal(t, "primary:5432", primary.Addr)
+	assert.Equal(t, "replica:5432", replica.Addr)
+
+	assert.True(t, ctx.HasNamed("replica", &replica))
+	assert.False(t, ctx.HasNamed("backup", &replica))
+
+	var client *testNamedClient
+	assert.False(t, ctx.Get(&client))
+
+	var pool *Pool
+	ctx.MustGet(&pool)
+	assert.Equal(t, []Named[*testNamedClient]{
+		{Name: "primary", Value: primary},
+		{Name: "replica", Value: replica},
+	}, pool.Clients)
+}
+
+func Test_Module_AddNamed__should_not_resolve_plain_dependency(t *testing.T) {
+	_, err := NewContext(func(m *Module) {
+		m.AddNamedInstance("primary", &testNamedClient{})
+		m.Add(func(*testNamedClient) string { return "" })
+	})
+	assert.ErrorContains(t, err, "di: unresolved provider dependency, dep=*di.testNamedClient")
+}
+
+func Test_NewContext__should_return_error_on_duplicate_named_providers(t *testing.T) {
+	_, err := NewContext(func(m *Module) {
+		m.AddNamedInstance("primary", &testNamedClient{})
+	}, func(m *Module) {
+		m.AddNamedInstance("primary", &testNamedClient{})
+	})
+	assert.ErrorContains(t, err, "di: duplicate named provider, type=*di.testNamedClient, name=primary")
+}
+
+func Test_Context_HasNamed__should_find_interface_typed_binding(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddNamed("memory", func() testStore { return &testMemoryStore{} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var store testStore
+	assert.True(t, ctx.GetNamed("memory", &store))
+	assert.True(t, ctx.HasNamed("memory", (*testStore)(nil)))
+	assert.False(t, ctx.HasNamed("memory", (**testMemoryStore)(nil)))
+}
+
+func Test_Context_Inject__should_inject_named_bindings_by_tags(t *testing.T) {
+	type Service struct {
+		Primary *testNamedClient `di:"name=primary"`
+		Replica *testNamedClient `di:"name=replica,required"`
+		Backup  *testNamedClient `di:"name=backup,required"`
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddNamedInstance("primary", &testNamedClient{Addr: "primary:5432"})
+		m.AddNamedInstance("replica", &testNamedClient{Addr: "replica:5432"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &Service{}
+	err = ctx.Inject(service)
+	assert.EqualError(t, err, "di: unfulfilled required fields, type=di.Service, fields=Backup")
+	assert.Equal(t, "primary:5432", service.Primary.Addr)
+	assert.Equal(t, "replica:5432", service.Replica.Addr)
+}
+
+func Test_Module_Add__should_inject_named_bindings_into_in_struct_dependencies(t *testing.T) {
+	type Deps struct {
+		In
+		Replica *testNamedClient `di:"name=replica"`
+		Addr    string
+	}
+	type Reader struct {
+		Client *testNamedClient
+		Addr   string
+	}
+
+	ctx, err := NewContext(func(m *Module) {
+		m.AddNamedInstance("primary", &testNamedClient{Addr: "primary:5432"})
+		m.AddNamedInstance("replica", &testNamedClient{Addr: "replica:5432"})
+		m.AddInstance("localhost")
+		m.Add(func(deps Deps) *Reader { return &Reader{Client: deps.Replica, Addr: deps.Addr} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := MustGet[*Reader](ctx)
+	assert.Equal(t, "replica:5432", reader.Client.Addr)
+	assert.Equal(t, "localhost", reader.Addr)
+}
+
+func Test_Module_Add__should_return_error_on_missing_named_dependency(t *testing.T) {
+	type Deps struct {
+		In
+		Replica *testNamedClient `di:"name=replica"`
+	}
+
+	_, err := NewContext(func(m *Module) {
+		m.AddNamedInstance("primary", &testNamedClient{})
+		m.Add(func(deps Deps) string { return "" })
+	})
+	assert.ErrorContains(t, err,
+		"di: unresolved named provider dependency, dep=*di.testNamedClient, name=replica")
+}
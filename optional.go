@@ -0,0 +1,62 @@
+package di
+
+import (
+	"reflect"
+)
+
+// Optional is a provider dependency which may be absent.
+// It is absent when there is no provider or the provider returned a nil instance.
+type Optional[T any] struct {
+	Value T
+	Valid bool
+}
+
+// Get returns the optional value and whether it is present.
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Valid
+}
+
+// Has is a provider dependency which reports whether an instance of T is present,
+// without holding it. It is false when Optional[T] would be absent.
+type Has[T any] bool
+
+// optional is implemented by all Optional and Has types.
+type optional interface {
+	elem() reflect.Type
+	with(instance interface{}) interface{}
+}
+
+func (o Optional[T]) elem() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (o Optional[T]) with(instance interface{}) interface{} {
+	return Optional[T]{Value: instance.(T), Valid: true}
+}
+
+func (h Has[T]) elem() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (h Has[T]) with(instance interface{}) interface{} {
+	return Has[T](true)
+}
+
+// optionalElem returns a value type when a type is an Optional or Has type.
+func optionalElem(typ reflect.Type) (reflect.Type, bool) {
+	opt, ok := reflect.Zero(typ).Interface().(optional)
+	if !ok {
+		return nil, false
+	}
+	return opt.elem(), true
+}
+
+// isNil returns true when an instance is nil or a nil pointer.
+func isNil(instance interface{}) bool {
+	if instance == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(instance)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
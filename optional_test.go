@@ -0,0 +1,114 @@
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetrics struct{}
+
+type testTracedService struct {
+	Metrics Optional[*testMetrics]
+}
+
+func newTestTracedService(metrics Optional[*testMetrics]) *testTracedService {
+	return &testTracedService{Metrics: metrics}
+}
+
+func Test_Optional__should_be_absent_when_provider_is_missing(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(newTestTracedService)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *testTracedService
+	ctx.MustGet(&service)
+
+	_, ok := service.Metrics.Get()
+	assert.False(t, ok)
+}
+
+func Test_Optional__should_be_present_when_provider_exists(t *testing.T) {
+	metrics := &testMetrics{}
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(metrics)
+		m.Add(newTestTracedService)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *testTracedService
+	ctx.MustGet(&service)
+
+	value, ok := service.Metrics.Get()
+	assert.True(t, ok)
+	assert.Same(t, metrics, value)
+}
+
+func testNilMetricsModule(m *Module) {
+	m.Add(func() (*testMetrics, error) { return nil, nil })
+}
+
+func Test_Optional__should_be_absent_when_provider_returns_nil(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Import(testNilMetricsModule)
+		m.Add(newTestTracedService)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *testTracedService
+	ctx.MustGet(&service)
+
+	_, ok := service.Metrics.Get()
+	assert.False(t, ok)
+}
+
+func Test_NewContext__should_return_error_when_required_dependency_is_nil(t *testing.T) {
+	_, err := NewContext(func(m *Module) {
+		m.Import(testNilMetricsModule)
+		m.Add(func(metrics *testMetrics) string { return "" })
+	})
+
+	assert.Contains(t, err.Error(), "di: nil instance, type=*di.testMetrics")
+}
+
+type testFeatureService struct {
+	HasMetrics bool
+}
+
+func newTestFeatureService(metrics Has[*testMetrics]) *testFeatureService {
+	return &testFeatureService{HasMetrics: bool(metrics)}
+}
+
+func Test_Has__should_be_false_when_instance_is_absent(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.Add(newTestFeatureService)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *testFeatureService
+	ctx.MustGet(&service)
+	assert.False(t, service.HasMetrics)
+}
+
+func Test_Has__should_be_true_when_instance_is_present(t *testing.T) {
+	ctx, err := NewContext(func(m *Module) {
+		m.AddInstance(&testMetrics{})
+		m.Add(newTestFeatureService)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var service *testFeatureService
+	ctx.MustGet(&service)
+	assert.True(t, service.HasMetrics)
+}
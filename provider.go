@@ -1,17 +1,32 @@
 package di
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 )
 
+// ErrNotAvailable is returned by a primary fallback provider to opt out,
+// so that the secondary provider is used instead.
+var ErrNotAvailable = errors.New("di: not available")
+
 // Provider creates a service instance.
 type Provider struct {
-	Module *Module
-	Name   string
-	Type   reflect.Type
-	Deps   []reflect.Type
-	Func   func(args []interface{}) (interface{}, error)
+	Module     *Module
+	Name       string
+	Type       reflect.Type
+	Impl       reflect.Type // Result type, differs from Type for providers added as interfaces.
+	Deps       []reflect.Type
+	Func       func(args []interface{}) (interface{}, error)
+	IsInstance bool   // Provides a prebuilt instance from AddInstance.
+	Private    bool   // Visible only to the providers of its module.
+	Group      bool   // Provides a group instance, resolved via slice dependencies.
+	Order      int    // Orders group instances, lower orders come first.
+	Releasable bool   // Its instance can be evicted, and is rebuilt on the next get.
+	AfterAll   bool   // Built after all other providers, other providers cannot depend on it.
+	Qualifier  string // Name of a named binding, resolved via []Named[T] dependencies.
+	Transient  bool   // Builds a new instance for each get and dependant.
 }
 
 func (c *Provider) String() string {
@@ -29,6 +44,9 @@ func newProvider(module *Module, f interface{}) *Provider {
 
 	// Result
 	switch ftyp.NumOut() {
+	case 0:
+		fname := getFuncName(fval)
+		panic(fmt.Sprintf(`di: provider must return at least an instance: %v`, fname))
 	case 1, 2:
 	default:
 		fname := getFuncName(fval)
@@ -36,17 +54,28 @@ func newProvider(module *Module, f interface{}) *Provider {
 	}
 	rtype := ftyp.Out(0)
 
-	// Deps
+	// Deps, In struct parameters are expanded to their fields.
 	deps := []reflect.Type{}
 	for i := 0; i < ftyp.NumIn(); i++ {
+		if in := ftyp.In(i); isInStruct(in) {
+			deps = append(deps, inDeps(in)...)
+			continue
+		}
 		deps = append(deps, ftyp.In(i))
 	}
 
 	// Function
 	function := func(args []interface{}) (interface{}, error) {
 		argv := []reflect.Value{}
-		for _, arg := range args {
-			argv = append(argv, reflect.ValueOf(arg))
+		for i := 0; i < ftyp.NumIn(); i++ {
+			if in := ftyp.In(i); isInStruct(in) {
+				v, n := newInStruct(in, args)
+				argv = append(argv, v)
+				args = args[n:]
+				continue
+			}
+			argv = append(argv, reflect.ValueOf(args[0]))
+			args = args[1:]
 		}
 
 		out := fval.Call(argv)
@@ -66,20 +95,244 @@ func newProvider(module *Module, f interface{}) *Provider {
 		Module: module,
 		Name:   getFuncName(fval),
 		Type:   rtype,
+		Impl:   rtype,
+		Deps:   deps,
+		Func:   function,
+	}
+}
+
+// newFallbackProvider creates a provider which calls the primary provider, and the secondary one
+// when the primary returns ErrNotAvailable. The dependencies of both providers are injected.
+func newFallbackProvider(primary *Provider, secondary *Provider) *Provider {
+	if primary.Type != secondary.Type {
+		panic(fmt.Sprintf("di: fallback providers must return the same type, primary=%v, secondary=%v",
+			primary.Type, secondary.Type))
+	}
+
+	n := len(primary.Deps)
+	deps := append(append([]reflect.Type{}, primary.Deps...), secondary.Deps...)
+	function := func(args []interface{}) (interface{}, error) {
+		instance, err := primary.Func(args[:n])
+		if errors.Is(err, ErrNotAvailable) {
+			return secondary.Func(args[n:])
+		}
+		return instance, err
+	}
+
+	return &Provider{
+		Module: primary.Module,
+		Name:   primary.Name + "|" + secondary.Name,
+		Type:   primary.Type,
+		Impl:   primary.Impl,
+		Deps:   deps,
+		Func:   function,
+	}
+}
+
+// newKeyedProvider creates a provider of a factory which caches instances by keys,
+// for example, keyFn func(TenantID) string and f func(*Config, TenantID) *TenantDB provide
+// a factory func(TenantID) *TenantDB. The last function parameter is passed to the factory,
+// the other ones are injected, and the factory returns one instance per key.
+func newKeyedProvider(module *Module, keyFn interface{}, f interface{}) *Provider {
+	fval := reflect.ValueOf(f)
+	if fval.Kind() != reflect.Func {
+		panic(fmt.Sprintf("di: provider must be a function: %T", f))
+	}
+	ftyp := fval.Type()
+	fname := getFuncName(fval)
+
+	switch {
+	case ftyp.NumIn() == 0:
+		panic(fmt.Sprintf(`di: keyed provider must have a factory parameter: %v`, fname))
+	case ftyp.NumOut() != 1 && ftyp.NumOut() != 2:
+		panic(fmt.Sprintf(`di: provider must return (instance) or (instance, error): %v`, fname))
+	}
+	param := ftyp.In(ftyp.NumIn() - 1)
+
+	kval := reflect.ValueOf(keyFn)
+	if !isKeyFunc(kval, param) {
+		panic(fmt.Sprintf("di: key function must be func(%v) comparable: %T", param, keyFn))
+	}
+
+	// Deps
+	deps := []reflect.Type{}
+	for i := 0; i < ftyp.NumIn()-1; i++ {
+		deps = append(deps, ftyp.In(i))
+	}
+
+	// Factory
+	outs := []reflect.Type{}
+	for i := 0; i < ftyp.NumOut(); i++ {
+		outs = append(outs, ftyp.Out(i))
+	}
+	factoryType := reflect.FuncOf([]reflect.Type{param}, outs, false)
+
+	function := func(args []interface{}) (interface{}, error) {
+		argv := []reflect.Value{}
+		for _, arg := range args {
+			argv = append(argv, reflect.ValueOf(arg))
+		}
+
+		mu := sync.Mutex{}
+		cache := map[interface{}][]reflect.Value{}
+		factory := reflect.MakeFunc(factoryType, func(in []reflect.Value) []reflect.Value {
+			key := kval.Call(in)[0].Interface()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if out, ok := cache[key]; ok {
+				return out
+			}
+
+			out := fval.Call(append(append([]reflect.Value{}, argv...), in[0]))
+			if len(out) == 1 || out[1].IsNil() {
+				cache[key] = out
+			}
+			return out
+		})
+		return factory.Interface(), nil
+	}
+
+	return &Provider{
+		Module: module,
+		Name:   fname,
+		Type:   factoryType,
+		Impl:   factoryType,
 		Deps:   deps,
 		Func:   function,
 	}
 }
 
+// isKeyFunc returns true when a value is a func(param) comparable.
+func isKeyFunc(kval reflect.Value, param reflect.Type) bool {
+	if !kval.IsValid() || kval.Kind() != reflect.Func || kval.IsNil() {
+		return false
+	}
+	ktyp := kval.Type()
+	return ktyp.NumIn() == 1 && ktyp.In(0) == param && ktyp.NumOut() == 1 && ktyp.Out(0).Comparable()
+}
+
+// newSubFieldProvider creates a provider which extracts a public struct field from a parent instance,
+// the parent type is passed as a value or a nil pointer, for example, Config{} or (*Config)(nil).
+func newSubFieldProvider(module *Module, parent interface{}, fieldName string) *Provider {
+	ptyp := reflect.TypeOf(parent)
+	styp := ptyp
+	if styp != nil && styp.Kind() == reflect.Ptr {
+		styp = styp.Elem()
+	}
+	if styp == nil || styp.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("di: sub field parent must be a struct or a struct pointer: %T", parent))
+	}
+
+	field, ok := styp.FieldByName(fieldName)
+	if !ok || !field.IsExported() {
+		panic(fmt.Sprintf("di: no public field, type=%v, field=%v", styp, fieldName))
+	}
+
+	function := func(args []interface{}) (interface{}, error) {
+		v := reflect.ValueOf(args[0])
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil, fmt.Errorf("di: nil sub field parent, type=%v, field=%v", ptyp, fieldName)
+			}
+			v = v.Elem()
+		}
+		return v.FieldByIndex(field.Index).Interface(), nil
+	}
+
+	return &Provider{
+		Module: module,
+		Name:   fmt.Sprintf("%v.%v", ptyp, fieldName),
+		Type:   field.Type,
+		Impl:   field.Type,
+		Deps:   []reflect.Type{ptyp},
+		Func:   function,
+	}
+}
+
+// newInvoker creates a provider which calls a function with injected dependencies for its side effects,
+// for example, configureServer(*Server, Config) error. The function returns nothing or an error.
+func newInvoker(module *Module, f interface{}) *Provider {
+	p, err := parseInvoker(module, f)
+	if err != nil {
+		panic(err.Error())
+	}
+	return p
+}
+
+// parseInvoker is newInvoker which returns an error on an invalid function.
+func parseInvoker(module *Module, f interface{}) (*Provider, error) {
+	fval := reflect.ValueOf(f)
+	if fval.Kind() != reflect.Func {
+		return nil, fmt.Errorf("di: invoke must be a function: %T", f)
+	}
+	ftyp := fval.Type()
+
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	switch {
+	case ftyp.NumOut() == 0:
+	case ftyp.NumOut() == 1 && ftyp.Out(0) == errorType:
+	default:
+		fname := getFuncName(fval)
+		return nil, fmt.Errorf(`di: invoke must return nothing or error: %v`, fname)
+	}
+
+	deps := []reflect.Type{}
+	for i := 0; i < ftyp.NumIn(); i++ {
+		deps = append(deps, ftyp.In(i))
+	}
+
+	function := func(args []interface{}) (interface{}, error) {
+		argv := []reflect.Value{}
+		for _, arg := range args {
+			argv = append(argv, reflect.ValueOf(arg))
+		}
+
+		out := fval.Call(argv)
+		if len(out) == 1 && !out[0].IsNil() {
+			return nil, out[0].Interface().(error)
+		}
+		return nil, nil
+	}
+
+	return &Provider{
+		Module: module,
+		Name:   getFuncName(fval),
+		Deps:   deps,
+		Func:   function,
+	}, nil
+}
+
 func newInstanceProvider(module *Module, instance interface{}) *Provider {
+	if instance == nil {
+		panic("di: cannot add nil instance")
+	}
+
 	typ := reflect.TypeOf(instance)
 	return &Provider{
 		Module: module,
 		Name:   typ.String(),
 		Type:   typ,
+		Impl:   typ,
 		Deps:   []reflect.Type{},
 		Func: func([]interface{}) (interface{}, error) {
 			return instance, nil
 		},
+		IsInstance: true,
+	}
+}
+
+// bindAs binds a provider to an interface type, the interface is passed as a nil pointer,
+// for example, (*Service)(nil).
+func bindAs(p *Provider, iface interface{}) {
+	ptr := reflect.TypeOf(iface)
+	if ptr == nil || ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("di: not an interface pointer: %T", iface))
+	}
+
+	typ := ptr.Elem()
+	if !p.Impl.Implements(typ) {
+		panic(fmt.Sprintf("di: provider does not implement interface, provider=%v, type=%v", p, typ))
 	}
+	p.Type = typ
 }
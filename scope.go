@@ -0,0 +1,108 @@
+package di
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// NewScope creates a child context, for example, per request, whose modules add scoped providers,
+// for example, of a user or a trace ID. The types and named bindings without scoped providers are
+// resolved from this context, group dependencies include its groups, the scope has its own instances,
+// and Close stops them.
+func (ctx *Context) NewScope(mfuncs ...ModuleFunc) (*Context, error) {
+	opts := Options{PanicFree: ctx.options.PanicFree, parent: ctx}
+	return NewContextWithOptions(opts, mfuncs...)
+}
+
+// Close stops the instances of a scope which implement the Stopper interface in reverse order,
+// and then runs the module stop hooks in reverse registration order. The instances resolved
+// from the parent context are not stopped.
+func (ctx *Context) Close() error {
+	errs := []error{}
+	for i := len(ctx.InstanceSlice) - 1; i >= 0; i-- {
+		stopper, ok := ctx.InstanceSlice[i].(Stopper)
+		if !ok {
+			continue
+		}
+		if err := stopper.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for i := len(ctx.stopHooks) - 1; i >= 0; i-- {
+		if err := ctx.stopHooks[i](context.Background()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveParent adds a built-in provider which gets an instance of a type from the parent of a scope,
+// a parent transient provider remains transient. It returns nil when the parents do not provide the type.
+func (ctx *Context) resolveParent(typ reflect.Type) *Provider {
+	parent := ctx.options.parent
+	for c := parent; c != nil; c = c.options.parent {
+		pp, ok := c.Providers[typ]
+		if !ok {
+			continue
+		}
+
+		p := &Provider{
+			Module: ctx.builtin,
+			Name:   pp.Name,
+			Type:   typ,
+			Impl:   pp.Impl,
+			Deps:   []reflect.Type{},
+			Func: func([]interface{}) (interface{}, error) {
+				instance, err := parent.getByType(typ)
+				if err == errNoInstance {
+					return nil, fmt.Errorf("di: no instance, type=%v", typ)
+				}
+				return instance, err
+			},
+			Transient: pp.Transient,
+		}
+		ctx.builtin.Providers = append(ctx.builtin.Providers, p)
+		ctx.Providers[typ] = p
+		return p
+	}
+	return nil
+}
+
+// scopeChain returns the parents of a scope from the root context, followed by the scope itself.
+func (ctx *Context) scopeChain() []*Context {
+	chain := []*Context{}
+	for c := ctx; c != nil; c = c.options.parent {
+		chain = append([]*Context{c}, chain...)
+	}
+	return chain
+}
+
+// proxyProvider returns a built-in provider which gets an instance of a provider from a parent context
+// of a scope, for example, of a named binding or a group, so that the scope shares the parent instances.
+func (ctx *Context) proxyProvider(parent *Context, pp *Provider) *Provider {
+	if p, ok := ctx.proxies[pp]; ok {
+		return p
+	}
+
+	p := &Provider{
+		Module: ctx.builtin,
+		Name:   pp.Name,
+		Type:   pp.Type,
+		Impl:   pp.Impl,
+		Deps:   []reflect.Type{},
+		Func: func([]interface{}) (interface{}, error) {
+			return parent.initInstance(pp)
+		},
+		Qualifier: pp.Qualifier,
+		Transient: pp.Transient,
+	}
+	if ctx.proxies == nil {
+		ctx.proxies = map[*Provider]*Provider{}
+	}
+	ctx.proxies[pp] = p
+	ctx.builtin.Providers = append(ctx.builtin.Providers, p)
+	return p
+}
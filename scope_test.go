@@ -0,0 +1,195 @@
+package di
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testScopeUser struct {
+	Name string
+}
+
+type testScopeHandler struct {
+	User    *testScopeUser
+	Greeter string
+	stopped bool
+}
+
+func (h *testScopeHandler) Stop() error {
+	h.stopped = true
+	return nil
+}
+
+func Test_Context_NewScope__should_add_scoped_providers_and_fall_back_to_parent(t *testing.T) {
+	app, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+		m.AddInstance(&testAppService{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scope, err := app.NewScope(func(m *Module) {
+		m.AddInstance(&testScopeUser{Name: "alice"})
+		m.Add(func(user *testScopeUser, greeter string) *testScopeHandler {
+			return &testScopeHandler{User: user, Greeter: greeter}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := MustGet[*testScopeHandler](scope)
+	assert.Equal(t, "alice", handler.User.Name)
+	assert.Equal(t, "hello", handler.Greeter)
+
+	_, ok := Get[*testScopeUser](app)
+	assert.False(t, ok)
+
+	assert.NoError(t, scope.Close())
+	assert.True(t, handler.stopped)
+	assert.False(t, MustGet[*testAppService](app).stopped)
+}
+
+func Test_Context_NewScope__should_get_and_inject_parent_instances(t *testing.T) {
+	app, err := NewContext(func(m *Module) {
+		m.AddInstance("hello")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scope, err := app.NewScope(func(m *Module) {
+		m.AddInstance(&testScopeUser{Name: "alice"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s string
+	assert.True(t, scope.Get(&s))
+	assert.Equal(t, "hello", s)
+	assert.Equal(t, "hello", MustGet[string](scope))
+
+	injected := struct {
+		Greeter string
+		User    *testScopeUser
+	}{}
+	assert.NoError(t, scope.Inject(&injected))
+	assert.Equal(t, "hello", injected.Greeter)
+	assert.Equal(t, "alice", injected.User.Name)
+}
+
+func Test_Context_NewScope__should_keep_parent_transient_providers_transient(t *testing.T) {
+	type Builder struct{ ID int }
+	type Client struct{ Builder *Builder }
+	type Server struct{ Builder *Builder }
+
+	calls := 0
+	app, err := NewContext(func(m *Module) {
+		m.AddTransient(func() *Builder { calls++; return &Builder{ID: calls} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scope, err := app.NewScope(func(m *Module) {
+		m.Add(func(b *Builder) *Client { return &Client{Builder: b} })
+		m.Add(func(b *Builder) *Server { return &Server{Builder: b} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotSame(t, MustGet[*Client](scope).Builder, MustGet[*Server](scope).Builder)
+	assert.Equal(t, 2, calls)
+}
+
+func Test_Context_NewScope__should_resolve_parent_named_dependencies(t *testing.T) {
+	type DB struct{ Name string }
+	type Handler struct {
+		Replica *DB
+		DBs     []Named[*DB]
+	}
+
+	primary := &DB{Name: "primary"}
+	replica := &DB{Name: "replica"}
+	app, err := NewContext(func(m *Module) {
+		m.AddNamedInstance("primary", primary)
+		m.AddNamedInstance("replica", replica)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Deps struct {
+		In
+		Replica *DB `di:"name=replica"`
+	}
+	scope, err := app.NewScope(func(m *Module) {
+		m.Add(func(deps Deps, dbs []Named[*DB]) *Handler {
+			return &Handler{Replica: deps.Replica, DBs: dbs}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := MustGet[*Handler](scope)
+	assert.Same(t, replica, handler.Replica)
+	assert.Equal(t, []Named[*DB]{{Name: "primary", Value: primary}, {Name: "replica", Value: replica}}, handler.DBs)
+
+	var db *DB
+	assert.True(t, scope.GetNamed("replica", &db))
+	assert.Same(t, replica, db)
+	assert.True(t, scope.HasNamed("primary", &db))
+}
+
+func Test_Context_NewScope__should_resolve_parent_group_dependencies(t *testing.T) {
+	type User struct{ Plugins []*testPlugin }
+
+	pluginA := &testPlugin{Name: "a"}
+	pluginB := &testPlugin{Name: "b"}
+	app, err := NewContext(func(m *Module) {
+		m.AddGroupInstances(pluginA)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scope, err := app.NewScope(func(m *Module) {
+		m.AddGroupInstances(pluginB)
+		m.Add(func(plugins []*testPlugin) *User { return &User{Plugins: plugins} })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := MustGet[*User](scope)
+	if assert.Len(t, user.Plugins, 2) {
+		assert.Same(t, pluginA, user.Plugins[0])
+		assert.Same(t, pluginB, user.Plugins[1])
+	}
+}
+
+func Test_Context_Close__should_run_stop_hooks_in_reverse_order_after_stoppers(t *testing.T) {
+	handler := &testScopeHandler{}
+	calls := []string{}
+	scope, err := NewContext(func(m *Module) {
+		m.AddInstance(handler)
+		m.OnStop(func(context.Context) error { calls = append(calls, "hook0"); return nil })
+		m.OnStop(func(context.Context) error {
+			assert.True(t, handler.stopped)
+			calls = append(calls, "hook1")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, scope.Close())
+	assert.Equal(t, []string{"hook1", "hook0"}, calls)
+}